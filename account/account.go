@@ -0,0 +1,148 @@
+package account
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/planer/backend/internal/database"
+	"github.com/planer/backend/internal/planner"
+	"github.com/planer/backend/internal/projects"
+	"github.com/planer/backend/internal/storage"
+)
+
+const defaultDeletionGrace = 30 * 24 * time.Hour
+
+func deletionGrace() time.Duration {
+	if value, err := time.ParseDuration(os.Getenv("ACCOUNT_DELETION_GRACE")); err == nil {
+		return value
+	}
+	return defaultDeletionGrace
+}
+
+func scheduleDeletion(ctx context.Context, userID int) (time.Time, error) {
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
+
+	scheduledAt := time.Now().UTC().Add(deletionGrace())
+	_, err := database.DB.ExecContext(ctx,
+		"UPDATE users SET deletion_scheduled_at = $1, updated_at = CURRENT_TIMESTAMP WHERE id = $2",
+		scheduledAt, userID,
+	)
+	return scheduledAt, err
+}
+
+func cancelDeletion(ctx context.Context, userID int) (bool, error) {
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
+
+	result, err := database.DB.ExecContext(ctx,
+		"UPDATE users SET deletion_scheduled_at = NULL, updated_at = CURRENT_TIMESTAMP WHERE id = $1 AND deletion_scheduled_at IS NOT NULL",
+		userID,
+	)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
+
+func StartDeletionPurger(interval time.Duration) {
+	if database.MockMode {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			if err := purgeDeletedAccounts(context.Background(), time.Now().UTC()); err != nil {
+				log.Printf("Ошибка при удалении аккаунтов: %v", err)
+			}
+			<-ticker.C
+		}
+	}()
+}
+
+func purgeDeletedAccounts(ctx context.Context, now time.Time) error {
+	rows, err := database.DB.QueryContext(ctx,
+		"SELECT id FROM users WHERE deletion_scheduled_at IS NOT NULL AND deletion_scheduled_at < $1",
+		now,
+	)
+	if err != nil {
+		return err
+	}
+
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return err
+		}
+		ids = append(ids, id)
+	}
+	if err := rows.Err(); err != nil {
+		rows.Close()
+		return err
+	}
+	rows.Close()
+
+	var errs []error
+	for _, userID := range ids {
+		if err := deleteAccount(ctx, userID); err != nil {
+			log.Printf("Не удалось удалить аккаунт пользователя %d: %v", userID, err)
+			errs = append(errs, fmt.Errorf("пользователь %d: %w", userID, err))
+			continue
+		}
+		log.Printf("Аккаунт пользователя %d удалён", userID)
+	}
+	return errors.Join(errs...)
+}
+
+func deleteAccount(ctx context.Context, userID int) error {
+	if err := planner.PurgeUserPlans(ctx, userID); err != nil {
+		return err
+	}
+	if err := planner.PurgeUserInteriors(ctx, userID); err != nil {
+		return err
+	}
+	if err := projects.PurgeUserProjects(ctx, userID); err != nil {
+		return err
+	}
+
+	tx, err := database.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, query := range []string{
+		"UPDATE plan_revisions SET author_id = NULL WHERE author_id = $1",
+		"UPDATE project_notes SET author_id = NULL WHERE author_id = $1",
+		"UPDATE project_attachments SET uploader_id = NULL WHERE uploader_id = $1",
+	} {
+		if _, err := tx.ExecContext(ctx, query, userID); err != nil {
+			return err
+		}
+	}
+	var avatarURL sql.NullString
+	if err := tx.QueryRowContext(ctx, "SELECT avatar_url FROM users WHERE id = $1", userID).Scan(&avatarURL); err != nil {
+		return err
+	}
+	if avatarURL.Valid {
+		if err := storage.ReleaseURLs(ctx, tx, avatarURL.String); err != nil {
+			return err
+		}
+	}
+	if _, err := tx.ExecContext(ctx, "DELETE FROM users WHERE id = $1", userID); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,199 @@
+package account
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"path"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/planer/backend/internal/audit"
+	"github.com/planer/backend/internal/auth"
+	"github.com/planer/backend/internal/planner"
+	"github.com/planer/backend/internal/projects"
+	"github.com/planer/backend/internal/storage"
+)
+
+type DeleteAccountRequest struct {
+	Password string `json:"password"`
+}
+
+type DeletionResponse struct {
+	DeletionScheduledAt string `json:"deletion_scheduled_at"`
+}
+
+func ExportHandler(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Требуется авторизация"})
+		return
+	}
+
+	ctx := c.Request.Context()
+	user, err := auth.GetUserByID(ctx, userID.(int))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Не удалось получить данные пользователя"})
+		return
+	}
+
+	plans, err := planner.ExportUserPlans(ctx, user.ID)
+	if err != nil {
+		log.Printf("Ошибка при экспорте планов пользователя %d: %v", user.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Не удалось выгрузить данные"})
+		return
+	}
+
+	interiors, err := planner.ListUserInteriors(ctx, user.ID, planner.InteriorFilter{IncludeDeleted: true})
+	if err != nil {
+		log.Printf("Ошибка при экспорте интерьеров пользователя %d: %v", user.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Не удалось выгрузить данные"})
+		return
+	}
+
+	userProjects, err := projects.ExportUserProjects(ctx, user.ID)
+	if err != nil {
+		log.Printf("Ошибка при экспорте проектов пользователя %d: %v", user.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Не удалось выгрузить данные"})
+		return
+	}
+
+	filename := fmt.Sprintf("planer_export_%d_%s.zip", user.ID, time.Now().Format("20060102"))
+	c.Header("Content-Type", "application/zip")
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	c.Status(http.StatusOK)
+
+	archive := zip.NewWriter(c.Writer)
+	defer archive.Close()
+
+	var urls []string
+	entries := map[string]interface{}{
+		"profile.json":   user,
+		"interiors.json": interiors,
+		"projects.json":  userProjects,
+	}
+	for _, export := range plans {
+		entries["plans/"+export.Plan.ID+".json"] = export
+		urls = append(urls, export.Plan.FloorPlan, export.Plan.Render3D)
+		for _, rev := range export.Revisions {
+			urls = append(urls, rev.Plan.FloorPlan, rev.Plan.Render3D)
+		}
+	}
+	for _, interior := range interiors {
+		urls = append(urls, interior.URL)
+	}
+	for _, project := range userProjects {
+		for _, attachment := range project.Attachments {
+			urls = append(urls, attachment.URL)
+		}
+	}
+
+	for name, value := range entries {
+		if err := writeJSON(archive, name, value); err != nil {
+			log.Printf("Ошибка при формировании архива: %v", err)
+			return
+		}
+	}
+
+	written := make(map[string]bool)
+	for _, url := range urls {
+		key, ok := storage.Default.KeyFromURL(url)
+		if !ok || written[key] {
+			continue
+		}
+		written[key] = true
+
+		data, err := storage.Default.Get(ctx, key)
+		if errors.Is(err, storage.ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			log.Printf("Не удалось прочитать файл %s для экспорта: %v", key, err)
+			continue
+		}
+		if err := writeFile(archive, path.Join("assets", key), data); err != nil {
+			log.Printf("Ошибка при формировании архива: %v", err)
+			return
+		}
+	}
+}
+
+func RequestDeletionHandler(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Требуется авторизация"})
+		return
+	}
+
+	var req DeleteAccountRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Некорректные данные: " + err.Error()})
+		return
+	}
+
+	err := auth.ConfirmIdentity(c.Request.Context(), userID.(int), c.GetString("sessionID"), req.Password)
+	if errors.Is(err, auth.ErrInvalidPassword) || errors.Is(err, auth.ErrReauthRequired) {
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Не удалось получить данные пользователя"})
+		return
+	}
+
+	scheduledAt, err := scheduleDeletion(c.Request.Context(), userID.(int))
+	if err != nil {
+		log.Printf("Ошибка при планировании удаления аккаунта: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Не удалось запланировать удаление аккаунта"})
+		return
+	}
+
+	audit.Record(c, audit.Entry{Action: audit.ActionDeletionRequest, TargetType: audit.TargetUser, TargetID: userID})
+
+	c.JSON(http.StatusAccepted, DeletionResponse{
+		DeletionScheduledAt: scheduledAt.Format(time.RFC3339),
+	})
+}
+
+func CancelDeletionHandler(c *gin.Context) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Требуется авторизация"})
+		return
+	}
+
+	cancelled, err := cancelDeletion(c.Request.Context(), userID.(int))
+	if err != nil {
+		log.Printf("Ошибка при отмене удаления аккаунта: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Не удалось отменить удаление аккаунта"})
+		return
+	}
+	if !cancelled {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Удаление аккаунта не запланировано"})
+		return
+	}
+
+	audit.Record(c, audit.Entry{Action: audit.ActionDeletionCancel, TargetType: audit.TargetUser, TargetID: userID})
+
+	c.Status(http.StatusNoContent)
+}
+
+func writeJSON(archive *zip.Writer, name string, value interface{}) error {
+	data, err := json.MarshalIndent(value, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFile(archive, name, data)
+}
+
+func writeFile(archive *zip.Writer, name string, data []byte) error {
+	w, err := archive.Create(name)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
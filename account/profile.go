@@ -0,0 +1,56 @@
+package account
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/planer/backend/internal/database"
+	"github.com/planer/backend/internal/storage"
+)
+
+const maxAvatarSize = 5 << 20
+
+var avatarTypes = map[string]bool{
+	"image/png":  true,
+	"image/jpeg": true,
+	"image/webp": true,
+}
+
+func setAvatar(ctx context.Context, userID int, url string) error {
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
+
+	tx, err := database.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var current sql.NullString
+	if err := tx.QueryRowContext(ctx, "SELECT avatar_url FROM users WHERE id = $1", userID).Scan(&current); err != nil {
+		return err
+	}
+	if current.String == url {
+		return nil
+	}
+
+	avatar := sql.NullString{String: url, Valid: url != ""}
+	if _, err := tx.ExecContext(ctx,
+		"UPDATE users SET avatar_url = $1, updated_at = CURRENT_TIMESTAMP WHERE id = $2",
+		avatar, userID,
+	); err != nil {
+		return err
+	}
+	if url != "" {
+		if err := storage.RetainURLs(ctx, tx, url); err != nil {
+			return err
+		}
+	}
+	if current.String != "" {
+		if err := storage.ReleaseURLs(ctx, tx, current.String); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,177 @@
+package account
+
+import (
+	"errors"
+	"io"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/planer/backend/internal/audit"
+	"github.com/planer/backend/internal/auth"
+	"github.com/planer/backend/internal/storage"
+)
+
+type UpdateProfileRequest struct {
+	Name     *string `json:"name" binding:"omitempty,max=100"`
+	Language *string `json:"language"`
+	Units    *string `json:"units"`
+}
+
+type ChangeEmailRequest struct {
+	Email    string `json:"email" binding:"required,email,max=100"`
+	Password string `json:"password"`
+}
+
+func UpdateProfileHandler(c *gin.Context) {
+	var req UpdateProfileRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Некорректные данные: " + err.Error()})
+		return
+	}
+
+	userID := c.GetInt("userID")
+	user, err := auth.UpdateProfile(c.Request.Context(), userID, auth.ProfileUpdate{
+		Name:     req.Name,
+		Language: req.Language,
+		Units:    req.Units,
+	})
+	if errors.Is(err, auth.ErrEmptyName) || errors.Is(err, auth.ErrInvalidLanguage) || errors.Is(err, auth.ErrInvalidUnits) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		log.Printf("Ошибка при обновлении профиля: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Не удалось обновить профиль"})
+		return
+	}
+
+	audit.Record(c, audit.Entry{Action: audit.ActionProfileUpdate, TargetType: audit.TargetUser, TargetID: userID})
+	c.JSON(http.StatusOK, user)
+}
+
+func ChangeEmailHandler(c *gin.Context) {
+	var req ChangeEmailRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Некорректные данные: " + err.Error()})
+		return
+	}
+
+	ctx := c.Request.Context()
+	userID := c.GetInt("userID")
+	user, err := auth.ChangeEmail(ctx, userID, c.GetString("sessionID"), req.Password, req.Email)
+	switch {
+	case errors.Is(err, auth.ErrInvalidPassword), errors.Is(err, auth.ErrReauthRequired):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, auth.ErrEmailExists):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, auth.ErrSameEmail):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		log.Printf("Ошибка при смене email: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Не удалось изменить email"})
+		return
+	}
+
+	audit.Record(c, audit.Entry{Action: audit.ActionEmailChange, TargetType: audit.TargetUser, TargetID: userID,
+		Details: map[string]interface{}{"email": user.Email, "pending_email": user.PendingEmail}})
+
+	if err := auth.SendEmailChangeConfirmation(ctx, user); err != nil {
+		log.Printf("Ошибка при отправке письма подтверждения: %v", err)
+	}
+	if err := auth.NotifyEmailChangeRequested(ctx, user); err != nil {
+		log.Printf("Ошибка при отправке уведомления о смене email: %v", err)
+	}
+
+	c.JSON(http.StatusAccepted, user)
+}
+
+func CancelEmailChangeHandler(c *gin.Context) {
+	user, err := auth.CancelEmailChange(c.Request.Context(), c.GetInt("userID"))
+	if err != nil {
+		log.Printf("Ошибка при отмене смены email: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Не удалось отменить смену email"})
+		return
+	}
+	c.JSON(http.StatusOK, user)
+}
+
+func UploadAvatarHandler(c *gin.Context) {
+	header, err := c.FormFile("avatar")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Файл не передан"})
+		return
+	}
+	if header.Size > maxAvatarSize {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Файл слишком большой"})
+		return
+	}
+
+	file, err := header.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Не удалось прочитать файл"})
+		return
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(io.LimitReader(file, maxAvatarSize+1))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Не удалось прочитать файл"})
+		return
+	}
+	if len(data) > maxAvatarSize {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Файл слишком большой"})
+		return
+	}
+
+	contentType := http.DetectContentType(data)
+	if !avatarTypes[contentType] {
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": "Аватар должен быть в формате PNG, JPEG или WebP"})
+		return
+	}
+
+	ctx := c.Request.Context()
+	url, err := storage.SaveAsset(ctx, "avatars", data, contentType)
+	if err != nil {
+		log.Printf("Ошибка при сохранении аватара: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Не удалось сохранить файл"})
+		return
+	}
+
+	userID := c.GetInt("userID")
+	if err := setAvatar(ctx, userID, url); err != nil {
+		log.Printf("Ошибка при обновлении аватара: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Не удалось обновить аватар"})
+		return
+	}
+
+	audit.Record(c, audit.Entry{Action: audit.ActionProfileUpdate, TargetType: audit.TargetUser, TargetID: userID,
+		Details: map[string]interface{}{"avatar": "uploaded"}})
+	respondProfile(c, userID)
+}
+
+func DeleteAvatarHandler(c *gin.Context) {
+	userID := c.GetInt("userID")
+	if err := setAvatar(c.Request.Context(), userID, ""); err != nil {
+		log.Printf("Ошибка при удалении аватара: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Не удалось удалить аватар"})
+		return
+	}
+
+	audit.Record(c, audit.Entry{Action: audit.ActionProfileUpdate, TargetType: audit.TargetUser, TargetID: userID,
+		Details: map[string]interface{}{"avatar": "removed"}})
+	respondProfile(c, userID)
+}
+
+func respondProfile(c *gin.Context, userID int) {
+	user, err := auth.GetUserByID(c.Request.Context(), userID)
+	if err != nil {
+		log.Printf("Ошибка при получении профиля: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Не удалось получить данные пользователя"})
+		return
+	}
+	c.JSON(http.StatusOK, user)
+}
@@ -0,0 +1,215 @@
+package audit
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/planer/backend/internal/database"
+)
+
+const (
+	ActionRegister        = "auth.register"
+	ActionLogin           = "auth.login"
+	ActionLoginFailed     = "auth.login_failed"
+	ActionLoginThrottled  = "auth.login_throttled"
+	ActionLoginLocked     = "auth.login_locked"
+	ActionOIDCLogin       = "auth.oidc_login"
+	ActionAPIKeyCreate    = "auth.api_key_create"
+	ActionAPIKeyRevoke    = "auth.api_key_revoke"
+	ActionLogout          = "auth.logout"
+	ActionLogoutAll       = "auth.logout_all"
+	ActionTokenReuse      = "auth.refresh_reuse"
+	ActionEmailVerified   = "auth.email_verified"
+	ActionPasswordReset   = "auth.password_reset"
+	ActionPasswordChange  = "auth.password_change"
+	ActionPlanCreate      = "plan.create"
+	ActionPlanUpdate      = "plan.update"
+	ActionPlanDelete      = "plan.delete"
+	ActionPlanRestore     = "plan.restore"
+	ActionAIPlan          = "ai.plan"
+	ActionAIInterior      = "ai.interior"
+	ActionDeletionRequest = "account.delete_requested"
+	ActionDeletionCancel  = "account.delete_cancelled"
+	ActionProfileUpdate   = "account.profile_update"
+	ActionEmailChange     = "account.email_change"
+	ActionRoleChange      = "admin.role_change"
+	ActionUserBlock       = "admin.user_block"
+	ActionUserUnblock     = "admin.user_unblock"
+	ActionDesignerAdd     = "project.designer_add"
+	ActionDesignerRemove  = "project.designer_remove"
+)
+
+const (
+	TargetUser     = "user"
+	TargetPlan     = "plan"
+	TargetInterior = "interior"
+	TargetAPIKey   = "api_key"
+	TargetProject  = "project"
+)
+
+const (
+	defaultQueryLimit = 100
+	maxQueryLimit     = 500
+)
+
+type Entry struct {
+	ActorID    int
+	Action     string
+	TargetType string
+	TargetID   interface{}
+	Details    map[string]interface{}
+}
+
+type Event struct {
+	ID         int64                  `json:"id"`
+	ActorID    int                    `json:"actor_id,omitempty"`
+	Action     string                 `json:"action"`
+	TargetType string                 `json:"target_type,omitempty"`
+	TargetID   string                 `json:"target_id,omitempty"`
+	IP         string                 `json:"ip,omitempty"`
+	Details    map[string]interface{} `json:"details,omitempty"`
+	CreatedAt  string                 `json:"created_at"`
+}
+
+type Filter struct {
+	ActorID    int
+	Action     string
+	TargetType string
+	TargetID   string
+	From       time.Time
+	To         time.Time
+	Limit      int
+	Offset     int
+}
+
+func Record(c *gin.Context, entry Entry) {
+	if entry.ActorID == 0 {
+		entry.ActorID = c.GetInt("userID")
+	}
+	if err := Log(c.Request.Context(), c.ClientIP(), entry); err != nil {
+		log.Printf("Ошибка при записи в журнал аудита (%s): %v", entry.Action, err)
+	}
+}
+
+func Log(ctx context.Context, ip string, entry Entry) error {
+	if database.MockMode {
+		return nil
+	}
+
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
+
+	var details interface{}
+	if len(entry.Details) > 0 {
+		data, err := json.Marshal(entry.Details)
+		if err != nil {
+			return err
+		}
+		details = string(data)
+	}
+
+	var actorID interface{}
+	if entry.ActorID > 0 {
+		actorID = entry.ActorID
+	}
+
+	targetID := ""
+	if entry.TargetID != nil {
+		targetID = fmt.Sprint(entry.TargetID)
+	}
+
+	_, err := database.DB.ExecContext(ctx,
+		"INSERT INTO audit_log (actor_id, action, target_type, target_id, ip, details) VALUES ($1, $2, $3, $4, $5, $6)",
+		actorID, entry.Action, entry.TargetType, targetID, ip, details,
+	)
+	return err
+}
+
+func Query(ctx context.Context, filter Filter) ([]Event, error) {
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
+
+	var conditions []string
+	var args []interface{}
+	addCondition := func(format string, value interface{}) {
+		args = append(args, value)
+		conditions = append(conditions, fmt.Sprintf(format, len(args)))
+	}
+
+	if filter.ActorID > 0 {
+		addCondition("actor_id = $%d", filter.ActorID)
+	}
+	if filter.Action != "" {
+		addCondition("action = $%d", filter.Action)
+	}
+	if filter.TargetType != "" {
+		addCondition("target_type = $%d", filter.TargetType)
+	}
+	if filter.TargetID != "" {
+		addCondition("target_id = $%d", filter.TargetID)
+	}
+	if !filter.From.IsZero() {
+		addCondition("created_at >= $%d", filter.From)
+	}
+	if !filter.To.IsZero() {
+		addCondition("created_at < $%d", filter.To)
+	}
+
+	where := ""
+	if len(conditions) > 0 {
+		where = "WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = defaultQueryLimit
+	}
+	if limit > maxQueryLimit {
+		limit = maxQueryLimit
+	}
+	offset := filter.Offset
+	if offset < 0 {
+		offset = 0
+	}
+	args = append(args, limit, offset)
+
+	rows, err := database.DB.QueryContext(ctx, fmt.Sprintf(`
+		SELECT id, actor_id, action, target_type, target_id, ip, details, created_at
+		FROM audit_log
+		%s
+		ORDER BY id DESC
+		LIMIT $%d OFFSET $%d`, where, len(args)-1, len(args)),
+		args...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	events := make([]Event, 0)
+	for rows.Next() {
+		var event Event
+		var actorID sql.NullInt64
+		var details []byte
+		var createdAt time.Time
+		if err := rows.Scan(&event.ID, &actorID, &event.Action, &event.TargetType, &event.TargetID,
+			&event.IP, &details, &createdAt); err != nil {
+			return nil, err
+		}
+		event.ActorID = int(actorID.Int64)
+		if len(details) > 0 {
+			if err := json.Unmarshal(details, &event.Details); err != nil {
+				return nil, err
+			}
+		}
+		event.CreatedAt = createdAt.Format(time.RFC3339)
+		events = append(events, event)
+	}
+	return events, rows.Err()
+}
@@ -0,0 +1,71 @@
+package audit
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func ListHandler(c *gin.Context) {
+	filter, err := parseFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Некорректные параметры фильтра: " + err.Error()})
+		return
+	}
+
+	events, err := Query(c.Request.Context(), filter)
+	if err != nil {
+		log.Printf("Ошибка при чтении журнала аудита: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Не удалось получить журнал аудита"})
+		return
+	}
+
+	c.JSON(http.StatusOK, events)
+}
+
+func parseFilter(c *gin.Context) (Filter, error) {
+	filter := Filter{
+		Action:     c.Query("action"),
+		TargetType: c.Query("target_type"),
+		TargetID:   c.Query("target_id"),
+	}
+
+	var err error
+	ints := map[string]*int{
+		"actor_id": &filter.ActorID,
+		"limit":    &filter.Limit,
+		"offset":   &filter.Offset,
+	}
+	for key, target := range ints {
+		value := c.Query(key)
+		if value == "" {
+			continue
+		}
+		if *target, err = strconv.Atoi(value); err != nil {
+			return filter, fmt.Errorf("%s: %v", key, err)
+		}
+		if *target < 0 {
+			return filter, fmt.Errorf("%s: значение не может быть отрицательным", key)
+		}
+	}
+
+	times := map[string]*time.Time{
+		"from": &filter.From,
+		"to":   &filter.To,
+	}
+	for key, target := range times {
+		value := c.Query(key)
+		if value == "" {
+			continue
+		}
+		if *target, err = time.Parse(time.RFC3339, value); err != nil {
+			return filter, fmt.Errorf("%s: %v", key, err)
+		}
+	}
+
+	return filter, nil
+}
@@ -0,0 +1,74 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/planer/backend/internal/database"
+)
+
+const (
+	defaultUserListLimit = 50
+	maxUserListLimit     = 200
+)
+
+type UserFilter struct {
+	Query  string
+	Role   string
+	Limit  int
+	Offset int
+}
+
+func ListUsers(ctx context.Context, filter UserFilter) ([]User, error) {
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
+
+	var conditions []string
+	var args []interface{}
+	if filter.Query != "" {
+		args = append(args, "%"+strings.ToLower(filter.Query)+"%")
+		conditions = append(conditions, fmt.Sprintf("(LOWER(email) LIKE $%d OR LOWER(name) LIKE $%d)", len(args), len(args)))
+	}
+	if filter.Role != "" {
+		args = append(args, filter.Role)
+		conditions = append(conditions, fmt.Sprintf("role = $%d", len(args)))
+	}
+
+	where := ""
+	if len(conditions) > 0 {
+		where = "WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = defaultUserListLimit
+	}
+	if limit > maxUserListLimit {
+		limit = maxUserListLimit
+	}
+	args = append(args, limit, filter.Offset)
+
+	rows, err := database.DB.QueryContext(ctx, fmt.Sprintf(`
+		SELECT %s
+		FROM users
+		%s
+		ORDER BY id
+		LIMIT $%d OFFSET $%d`, userColumns, where, len(args)-1, len(args)),
+		args...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	users := make([]User, 0)
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, *user)
+	}
+	return users, rows.Err()
+}
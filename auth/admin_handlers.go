@@ -0,0 +1,134 @@
+package auth
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/planer/backend/internal/audit"
+)
+
+type SetRoleRequest struct {
+	Role string `json:"role" binding:"required"`
+}
+
+type SetBlockedRequest struct {
+	Blocked bool `json:"blocked"`
+}
+
+func ListUsersHandler(c *gin.Context) {
+	filter := UserFilter{Query: c.Query("q"), Role: c.Query("role")}
+	if filter.Role != "" && !ValidRole(filter.Role) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": ErrUnknownRole.Error()})
+		return
+	}
+	for key, target := range map[string]*int{"limit": &filter.Limit, "offset": &filter.Offset} {
+		if value := c.Query(key); value != "" {
+			parsed, err := strconv.Atoi(value)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Некорректный параметр " + key})
+				return
+			}
+			*target = parsed
+		}
+	}
+
+	users, err := ListUsers(c.Request.Context(), filter)
+	if err != nil {
+		log.Printf("Ошибка при получении пользователей: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Не удалось получить пользователей"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"users": users, "roles": Roles})
+}
+
+func SetUserRoleHandler(c *gin.Context) {
+	userID, ok := adminTargetUser(c)
+	if !ok {
+		return
+	}
+
+	var req SetRoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Некорректные данные: " + err.Error()})
+		return
+	}
+
+	err := SetUserRole(c.Request.Context(), userID, req.Role)
+	if errors.Is(err, ErrUnknownRole) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !respondAdminChange(c, err, "Не удалось изменить роль") {
+		return
+	}
+
+	audit.Record(c, audit.Entry{Action: audit.ActionRoleChange, TargetType: audit.TargetUser, TargetID: userID,
+		Details: map[string]interface{}{"role": req.Role}})
+	respondUser(c, userID)
+}
+
+func SetUserBlockedHandler(c *gin.Context) {
+	userID, ok := adminTargetUser(c)
+	if !ok {
+		return
+	}
+
+	var req SetBlockedRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Некорректные данные: " + err.Error()})
+		return
+	}
+
+	err := SetUserBlocked(c.Request.Context(), userID, req.Blocked)
+	if !respondAdminChange(c, err, "Не удалось изменить блокировку") {
+		return
+	}
+
+	action := audit.ActionUserUnblock
+	if req.Blocked {
+		action = audit.ActionUserBlock
+	}
+	audit.Record(c, audit.Entry{Action: action, TargetType: audit.TargetUser, TargetID: userID})
+	respondUser(c, userID)
+}
+
+func adminTargetUser(c *gin.Context) (int, bool) {
+	userID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Некорректный ID пользователя"})
+		return 0, false
+	}
+	if userID == c.GetInt("userID") {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Нельзя изменить собственную роль или блокировку"})
+		return 0, false
+	}
+	return userID, true
+}
+
+func respondAdminChange(c *gin.Context, err error, failed string) bool {
+	if errors.Is(err, sql.ErrNoRows) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Пользователь не найден"})
+		return false
+	}
+	if err != nil {
+		log.Printf("%s: %v", failed, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": failed})
+		return false
+	}
+	return true
+}
+
+func respondUser(c *gin.Context, userID int) {
+	user, err := GetUserByID(c.Request.Context(), userID)
+	if err != nil {
+		log.Printf("Ошибка при получении пользователя: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Не удалось получить пользователя"})
+		return
+	}
+	c.JSON(http.StatusOK, user)
+}
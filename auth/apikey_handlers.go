@@ -0,0 +1,109 @@
+package auth
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/planer/backend/internal/audit"
+)
+
+type CreateAPIKeyRequest struct {
+	Name      string     `json:"name" binding:"required,max=100"`
+	Scopes    []string   `json:"scopes" binding:"required,min=1"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+type CreateAPIKeyResponse struct {
+	APIKey
+	Key string `json:"key"`
+}
+
+func rejectAPIKeyAuth(c *gin.Context) bool {
+	if c.GetInt("apiKeyID") == 0 {
+		return false
+	}
+	c.JSON(http.StatusForbidden, gin.H{"error": "Управление API-ключами доступно только после входа в аккаунт"})
+	return true
+}
+
+func ListAPIKeysHandler(c *gin.Context) {
+	if rejectAPIKeyAuth(c) {
+		return
+	}
+
+	keys, err := ListAPIKeys(c.Request.Context(), c.GetInt("userID"))
+	if err != nil {
+		log.Printf("Ошибка при получении API-ключей: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Не удалось получить API-ключи"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"keys": keys, "scopes": AllScopes})
+}
+
+func CreateAPIKeyHandler(c *gin.Context) {
+	if rejectAPIKeyAuth(c) {
+		return
+	}
+
+	var req CreateAPIKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Некорректные данные: " + err.Error()})
+		return
+	}
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Срок действия ключа должен быть в будущем"})
+		return
+	}
+
+	userID := c.GetInt("userID")
+	key, token, err := CreateAPIKey(c.Request.Context(), userID, req.Name, req.Scopes, req.ExpiresAt)
+	if errors.Is(err, ErrAPIKeyScope) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if errors.Is(err, ErrAPIKeyLimit) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		log.Printf("Ошибка при создании API-ключа: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Не удалось создать API-ключ"})
+		return
+	}
+
+	audit.Record(c, audit.Entry{Action: audit.ActionAPIKeyCreate, TargetType: audit.TargetAPIKey, TargetID: key.ID,
+		Details: map[string]interface{}{"name": key.Name, "scopes": key.Scopes}})
+
+	c.JSON(http.StatusCreated, CreateAPIKeyResponse{APIKey: *key, Key: token})
+}
+
+func RevokeAPIKeyHandler(c *gin.Context) {
+	if rejectAPIKeyAuth(c) {
+		return
+	}
+
+	keyID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Некорректный ID ключа"})
+		return
+	}
+
+	err = RevokeAPIKey(c.Request.Context(), c.GetInt("userID"), keyID)
+	if errors.Is(err, ErrAPIKeyNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		log.Printf("Ошибка при отзыве API-ключа: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Не удалось отозвать API-ключ"})
+		return
+	}
+
+	audit.Record(c, audit.Entry{Action: audit.ActionAPIKeyRevoke, TargetType: audit.TargetAPIKey, TargetID: keyID})
+	c.Status(http.StatusNoContent)
+}
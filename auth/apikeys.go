@@ -0,0 +1,234 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/planer/backend/internal/database"
+)
+
+const (
+	ScopePlansRead     = "plans:read"
+	ScopePlansWrite    = "plans:write"
+	ScopeProjectsRead  = "projects:read"
+	ScopeProjectsWrite = "projects:write"
+	ScopeAIGenerate    = "ai:generate"
+)
+
+var AllScopes = []string{ScopePlansRead, ScopePlansWrite, ScopeProjectsRead, ScopeProjectsWrite, ScopeAIGenerate}
+
+const (
+	apiKeyPrefix      = "plk_"
+	maxAPIKeysPerUser = 20
+	lastUsedPrecision = time.Minute
+)
+
+var (
+	ErrAPIKeyInvalid  = errors.New("недействительный API-ключ")
+	ErrAPIKeyScope    = errors.New("неизвестная область доступа")
+	ErrAPIKeyLimit    = errors.New("достигнут предел количества API-ключей")
+	ErrAPIKeyNotFound = errors.New("API-ключ не найден")
+)
+
+type APIKey struct {
+	ID         int        `json:"id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Scopes     []string   `json:"scopes"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+type apiKeyPrincipal struct {
+	keyID      int
+	userID     int
+	role       string
+	scopes     []string
+	lastUsedAt sql.NullTime
+}
+
+func IsAPIKey(token string) bool {
+	return strings.HasPrefix(token, apiKeyPrefix)
+}
+
+func normalizeScopes(scopes []string) ([]string, error) {
+	result := make([]string, 0, len(scopes))
+	seen := make(map[string]bool)
+	for _, scope := range scopes {
+		scope = strings.TrimSpace(scope)
+		if seen[scope] {
+			continue
+		}
+		if !HasScope(AllScopes, scope) {
+			return nil, ErrAPIKeyScope
+		}
+		seen[scope] = true
+		result = append(result, scope)
+	}
+	return result, nil
+}
+
+func HasScope(scopes []string, scope string) bool {
+	for _, s := range scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+func CreateAPIKey(ctx context.Context, userID int, name string, scopes []string, expiresAt *time.Time) (*APIKey, string, error) {
+	scopes, err := normalizeScopes(scopes)
+	if err != nil {
+		return nil, "", err
+	}
+
+	data := make([]byte, 24)
+	if _, err := rand.Read(data); err != nil {
+		return nil, "", err
+	}
+	secret := hex.EncodeToString(data)
+	token := apiKeyPrefix + secret
+
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
+
+	tx, err := database.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, "UPDATE users SET id = id WHERE id = $1", userID); err != nil {
+		return nil, "", err
+	}
+
+	var count int
+	err = tx.QueryRowContext(ctx,
+		"SELECT COUNT(*) FROM api_keys WHERE user_id = $1 AND revoked_at IS NULL",
+		userID,
+	).Scan(&count)
+	if err != nil {
+		return nil, "", err
+	}
+	if count >= maxAPIKeysPerUser {
+		return nil, "", ErrAPIKeyLimit
+	}
+
+	key := &APIKey{
+		Name:      name,
+		Prefix:    apiKeyPrefix + secret[:8],
+		Scopes:    scopes,
+		ExpiresAt: expiresAt,
+	}
+	err = tx.QueryRowContext(ctx,
+		`INSERT INTO api_keys (user_id, name, prefix, key_hash, scopes, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, created_at`,
+		userID, name, key.Prefix, hashRefreshToken(token), database.StringArray(&key.Scopes), expiresAt,
+	).Scan(&key.ID, &key.CreatedAt)
+	if err != nil {
+		return nil, "", err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, "", err
+	}
+	return key, token, nil
+}
+
+func ListAPIKeys(ctx context.Context, userID int) ([]APIKey, error) {
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
+
+	rows, err := database.DB.QueryContext(ctx,
+		`SELECT id, name, prefix, scopes, expires_at, last_used_at, created_at
+		FROM api_keys
+		WHERE user_id = $1 AND revoked_at IS NULL
+		ORDER BY created_at DESC, id DESC`,
+		userID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := []APIKey{}
+	for rows.Next() {
+		var key APIKey
+		var expiresAt, lastUsedAt sql.NullTime
+		if err := rows.Scan(&key.ID, &key.Name, &key.Prefix, database.StringArray(&key.Scopes),
+			&expiresAt, &lastUsedAt, &key.CreatedAt); err != nil {
+			return nil, err
+		}
+		if expiresAt.Valid {
+			key.ExpiresAt = &expiresAt.Time
+		}
+		if lastUsedAt.Valid {
+			key.LastUsedAt = &lastUsedAt.Time
+		}
+		keys = append(keys, key)
+	}
+	return keys, rows.Err()
+}
+
+func RevokeAPIKey(ctx context.Context, userID, keyID int) error {
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
+
+	result, err := database.DB.ExecContext(ctx,
+		"UPDATE api_keys SET revoked_at = CURRENT_TIMESTAMP WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL",
+		keyID, userID,
+	)
+	if err != nil {
+		return err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return ErrAPIKeyNotFound
+	}
+	return nil
+}
+
+func authenticateAPIKey(ctx context.Context, token string) (*apiKeyPrincipal, error) {
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
+
+	var principal apiKeyPrincipal
+	var expiresAt sql.NullTime
+	err := database.DB.QueryRowContext(ctx,
+		`SELECT k.id, k.user_id, u.role, k.scopes, k.expires_at, k.last_used_at
+		FROM api_keys k
+		JOIN users u ON u.id = k.user_id
+		WHERE k.key_hash = $1 AND k.revoked_at IS NULL AND u.blocked_at IS NULL`,
+		hashRefreshToken(token),
+	).Scan(&principal.keyID, &principal.userID, &principal.role, database.StringArray(&principal.scopes),
+		&expiresAt, &principal.lastUsedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrAPIKeyInvalid
+	}
+	if err != nil {
+		return nil, err
+	}
+	if expiresAt.Valid && time.Now().After(expiresAt.Time) {
+		return nil, ErrAPIKeyInvalid
+	}
+
+	if !principal.lastUsedAt.Valid || time.Since(principal.lastUsedAt.Time) > lastUsedPrecision {
+		if _, err := database.DB.ExecContext(ctx,
+			"UPDATE api_keys SET last_used_at = CURRENT_TIMESTAMP WHERE id = $1",
+			principal.keyID,
+		); err != nil {
+			return nil, err
+		}
+	}
+	return &principal, nil
+}
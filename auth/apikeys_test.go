@@ -0,0 +1,90 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func newScopedRouter() *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	ok := func(c *gin.Context) { c.Status(http.StatusOK) }
+
+	router.GET("/plans", AuthMiddleware(ScopePlansRead), ok)
+	router.POST("/plans", AuthMiddleware(ScopePlansWrite), ok)
+	plans := router.Group("/projects", AuthMiddleware(ScopeProjectsRead))
+	plans.GET("", ok)
+	plans.POST("", RequireScope(ScopeProjectsWrite), ok)
+	router.GET("/profile", AuthMiddleware(), ok)
+	return router
+}
+
+func requestWithKey(router *gin.Engine, method, path, key string) *httptest.ResponseRecorder {
+	recorder := httptest.NewRecorder()
+	req := httptest.NewRequest(method, path, nil)
+	req.Header.Set("X-API-Key", key)
+	router.ServeHTTP(recorder, req)
+	return recorder
+}
+
+func TestAPIKeyScopes(t *testing.T) {
+	openTestDB(t)
+	ctx := context.Background()
+
+	user, err := RegisterUser(ctx, "Мария", "user@example.com", "password123")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, token, err := CreateAPIKey(ctx, user.ID, "read-only", []string{ScopePlansRead, ScopeProjectsRead}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	router := newScopedRouter()
+	cases := []struct {
+		method, path string
+		want         int
+	}{
+		{http.MethodGet, "/plans", http.StatusOK},
+		{http.MethodPost, "/plans", http.StatusForbidden},
+		{http.MethodGet, "/projects", http.StatusOK},
+		{http.MethodPost, "/projects", http.StatusForbidden},
+		{http.MethodGet, "/profile", http.StatusForbidden},
+	}
+	for _, tc := range cases {
+		recorder := requestWithKey(router, tc.method, tc.path, token)
+		if recorder.Code != tc.want {
+			t.Errorf("%s %s with a read-only key: status %d, want %d", tc.method, tc.path, recorder.Code, tc.want)
+		}
+	}
+
+	if recorder := requestWithKey(router, http.MethodGet, "/plans", "plk_unknown"); recorder.Code != http.StatusUnauthorized {
+		t.Errorf("unknown key: status %d, want 401", recorder.Code)
+	}
+}
+
+func TestCreateAPIKeyLimit(t *testing.T) {
+	openTestDB(t)
+	ctx := context.Background()
+
+	user, err := RegisterUser(ctx, "Мария", "user@example.com", "password123")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < maxAPIKeysPerUser; i++ {
+		if _, _, err := CreateAPIKey(ctx, user.ID, "key", []string{ScopePlansRead}, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, _, err := CreateAPIKey(ctx, user.ID, "key", []string{ScopePlansRead}, nil); !errors.Is(err, ErrAPIKeyLimit) {
+		t.Fatalf("key over the limit: err = %v, want ErrAPIKeyLimit", err)
+	}
+	if _, _, err := CreateAPIKey(ctx, user.ID, "key", []string{"plans:delete"}, nil); !errors.Is(err, ErrAPIKeyScope) {
+		t.Fatalf("unknown scope: err = %v, want ErrAPIKeyScope", err)
+	}
+}
@@ -1,114 +1,181 @@
 package auth
 
 import (
+	"context"
+	"database/sql"
 	"errors"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
 	"github.com/planer/backend/internal/database"
 	"golang.org/x/crypto/bcrypt"
 )
 
-var jwtKey = []byte("your_secret_key")
-
 type User struct {
-	ID           int       `json:"id"`
-	Name         string    `json:"name"`
-	Email        string    `json:"email"`
-	PasswordHash string    `json:"-"`
-	CreatedAt    time.Time `json:"created_at"`
-	UpdatedAt    time.Time `json:"updated_at"`
+	ID                  int        `json:"id"`
+	Name                string     `json:"name"`
+	Email               string     `json:"email"`
+	PendingEmail        string     `json:"pending_email,omitempty"`
+	PasswordHash        string     `json:"-"`
+	Role                string     `json:"role"`
+	AvatarURL           string     `json:"avatar_url,omitempty"`
+	Language            string     `json:"language"`
+	Units               string     `json:"units"`
+	EmailVerified       bool       `json:"email_verified"`
+	EmailVerifiedAt     *time.Time `json:"email_verified_at,omitempty"`
+	BlockedAt           *time.Time `json:"blocked_at,omitempty"`
+	DeletionScheduledAt *time.Time `json:"deletion_scheduled_at,omitempty"`
+	CreatedAt           time.Time  `json:"created_at"`
+	UpdatedAt           time.Time  `json:"updated_at"`
 }
 
 type Claims struct {
-	UserID int    `json:"user_id"`
-	Email  string `json:"email"`
+	UserID    int    `json:"user_id"`
+	Email     string `json:"email"`
+	Role      string `json:"role,omitempty"`
+	SessionID string `json:"sid,omitempty"`
 	jwt.RegisteredClaims
 }
 
-func RegisterUser(name, email, password string) error {
-	var count int
-	err := database.DB.QueryRow("SELECT COUNT(*) FROM users WHERE email = $1", email).Scan(&count)
-	if err != nil {
-		return err
+var (
+	ErrEmailExists     = errors.New("пользователь с таким email уже существует")
+	ErrInvalidPassword = errors.New("неверный пароль")
+	ErrUserBlocked     = errors.New("аккаунт заблокирован")
+)
+
+const userColumns = "id, name, email, pending_email, role, avatar_url, language, units, email_verified_at, blocked_at, deletion_scheduled_at, created_at, updated_at"
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanUser(row rowScanner, extra ...interface{}) (*User, error) {
+	var user User
+	var pendingEmail, avatarURL sql.NullString
+	var emailVerifiedAt, blockedAt, deletionScheduledAt sql.NullTime
+	dest := []interface{}{&user.ID, &user.Name, &user.Email, &pendingEmail, &user.Role, &avatarURL, &user.Language, &user.Units,
+		&emailVerifiedAt, &blockedAt, &deletionScheduledAt, &user.CreatedAt, &user.UpdatedAt}
+	if err := row.Scan(append(dest, extra...)...); err != nil {
+		return nil, err
+	}
+	user.PendingEmail = pendingEmail.String
+	user.AvatarURL = avatarURL.String
+	if emailVerifiedAt.Valid {
+		user.EmailVerified = true
+		user.EmailVerifiedAt = &emailVerifiedAt.Time
+	}
+	if blockedAt.Valid {
+		user.BlockedAt = &blockedAt.Time
 	}
-	if count > 0 {
-		return errors.New("пользователь с таким email уже существует")
+	if deletionScheduledAt.Valid {
+		user.DeletionScheduledAt = &deletionScheduledAt.Time
 	}
+	return &user, nil
+}
 
+func RegisterUser(ctx context.Context, name, email, password string) (*User, error) {
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	_, err = database.DB.Exec(
-		"INSERT INTO users (name, email, password_hash) VALUES ($1, $2, $3)",
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
+
+	user, err := scanUser(database.DB.QueryRowContext(ctx,
+		"INSERT INTO users (name, email, password_hash) VALUES ($1, $2, $3) RETURNING "+userColumns,
 		name, email, string(hashedPassword),
-	)
-	return err
+	))
+	if database.IsUniqueViolation(err) {
+		return nil, ErrEmailExists
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return user, nil
 }
 
-func AuthenticateUser(email, password string) (string, error) {
-	var user User
-	err := database.DB.QueryRow(
-		"SELECT id, name, email, password_hash FROM users WHERE email = $1",
+func AuthenticateUser(ctx context.Context, email, password string) (*User, error) {
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
+
+	var passwordHash string
+	user, err := scanUser(database.DB.QueryRowContext(ctx,
+		"SELECT "+userColumns+", password_hash FROM users WHERE email = $1",
 		email,
-	).Scan(&user.ID, &user.Name, &user.Email, &user.PasswordHash)
+	), &passwordHash)
 	if err != nil {
-		return "", errors.New("неверный email или пароль")
+		return nil, errors.New("неверный email или пароль")
 	}
+	user.PasswordHash = passwordHash
 
 	err = bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password))
 	if err != nil {
-		return "", errors.New("неверный email или пароль")
+		return nil, errors.New("неверный email или пароль")
+	}
+	if user.BlockedAt != nil {
+		return nil, ErrUserBlocked
 	}
-	expirationTime := time.Now().Add(24 * time.Hour)
+
+	return user, nil
+}
+
+func GenerateToken(user *User, sessionID string) (string, error) {
+	expirationTime := time.Now().Add(accessTTL())
 	claims := &Claims{
-		UserID: user.ID,
-		Email:  user.Email,
+		UserID:    user.ID,
+		Email:     user.Email,
+		Role:      user.Role,
+		SessionID: sessionID,
 		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    tokenIssuer(),
+			Audience:  jwt.ClaimStrings{tokenAudience()},
 			ExpiresAt: jwt.NewNumericDate(expirationTime),
+			NotBefore: jwt.NewNumericDate(time.Now()),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 			Subject:   "user_token",
+			ID:        uuid.New().String(),
 		},
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	tokenString, err := token.SignedString(jwtKey)
-	if err != nil {
-		return "", err
-	}
-
-	return tokenString, nil
+	return activeKeys().sign(claims)
 }
 
 func ValidateToken(tokenString string) (*Claims, error) {
 	claims := &Claims{}
-
-	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
-		return jwtKey, nil
-	})
-
-	if err != nil {
+	if err := activeKeys().parse(tokenString, claims, tokenAudience()); err != nil {
 		return nil, err
 	}
+	return claims, nil
+}
 
-	if !token.Valid {
-		return nil, errors.New("невалидный токен")
-	}
+func GetUserByID(ctx context.Context, userID int) (*User, error) {
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
 
-	return claims, nil
+	return scanUser(database.DB.QueryRowContext(ctx,
+		"SELECT "+userColumns+" FROM users WHERE id = $1",
+		userID,
+	))
 }
 
-func GetUserByID(userID int) (*User, error) {
-	var user User
-	err := database.DB.QueryRow(
-		"SELECT id, name, email, created_at, updated_at FROM users WHERE id = $1",
+func CheckPassword(ctx context.Context, userID int, password string) error {
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
+
+	var passwordHash string
+	err := database.DB.QueryRowContext(ctx,
+		"SELECT password_hash FROM users WHERE id = $1",
 		userID,
-	).Scan(&user.ID, &user.Name, &user.Email, &user.CreatedAt, &user.UpdatedAt)
+	).Scan(&passwordHash)
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	return &user, nil
+	if err := bcrypt.CompareHashAndPassword([]byte(passwordHash), []byte(password)); err != nil {
+		return ErrInvalidPassword
+	}
+	return nil
 }
@@ -0,0 +1,120 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	authRealm     = "planer"
+	accessCookie  = "access_token"
+	refreshCookie = "refresh_token"
+	csrfCookie    = "csrf_token"
+	csrfHeader    = "X-CSRF-Token"
+	apiKeyHeader  = "X-API-Key"
+)
+
+type cookieSettings struct {
+	enabled bool
+	secure  bool
+	domain  string
+}
+
+func cookieConfig() cookieSettings {
+	return cookieSettings{
+		enabled: os.Getenv("AUTH_COOKIES") == "true",
+		secure:  getEnv("AUTH_COOKIE_SECURE", "true") != "false",
+		domain:  os.Getenv("AUTH_COOKIE_DOMAIN"),
+	}
+}
+
+func setAuthCookies(c *gin.Context, pair *TokenPair) error {
+	config := cookieConfig()
+	if !config.enabled {
+		return nil
+	}
+
+	data := make([]byte, 32)
+	if _, err := rand.Read(data); err != nil {
+		return err
+	}
+	csrfToken := base64.RawURLEncoding.EncodeToString(data)
+	refreshAge := int(refreshTTL().Seconds())
+
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(accessCookie, pair.AccessToken, pair.ExpiresIn, "/", config.domain, config.secure, true)
+	c.SetCookie(refreshCookie, pair.RefreshToken, refreshAge, "/", config.domain, config.secure, true)
+	c.SetCookie(csrfCookie, csrfToken, refreshAge, "/", config.domain, config.secure, false)
+	return nil
+}
+
+func clearAuthCookies(c *gin.Context) {
+	config := cookieConfig()
+	if !config.enabled {
+		return
+	}
+
+	c.SetSameSite(http.SameSiteLaxMode)
+	for _, name := range []string{accessCookie, refreshCookie} {
+		c.SetCookie(name, "", -1, "/", config.domain, config.secure, true)
+	}
+	c.SetCookie(csrfCookie, "", -1, "/", config.domain, config.secure, false)
+}
+
+func validCSRF(c *gin.Context) bool {
+	switch c.Request.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return true
+	}
+
+	expected, err := c.Cookie(csrfCookie)
+	if err != nil || expected == "" {
+		return false
+	}
+	actual := c.GetHeader(csrfHeader)
+	return subtle.ConstantTimeCompare([]byte(expected), []byte(actual)) == 1
+}
+
+func extractToken(c *gin.Context) (token string, fromCookie bool, err error) {
+	if key := strings.TrimSpace(c.GetHeader(apiKeyHeader)); key != "" {
+		return key, false, nil
+	}
+	if header := c.GetHeader("Authorization"); header != "" {
+		scheme, value, found := strings.Cut(header, " ")
+		value = strings.TrimSpace(value)
+		if !found || !strings.EqualFold(scheme, "Bearer") || value == "" {
+			return "", false, fmt.Errorf("expected Bearer scheme")
+		}
+		return value, false, nil
+	}
+
+	if cookieConfig().enabled {
+		if value, err := c.Cookie(accessCookie); err == nil && value != "" {
+			return value, true, nil
+		}
+	}
+	return "", false, nil
+}
+
+func challenge(code, description string) string {
+	value := fmt.Sprintf("Bearer realm=%q", authRealm)
+	if code != "" {
+		value += fmt.Sprintf(", error=%q", code)
+	}
+	if description != "" {
+		value += fmt.Sprintf(", error_description=%q", description)
+	}
+	return value
+}
+
+func abortUnauthorized(c *gin.Context, code, description, message string) {
+	c.Header("WWW-Authenticate", challenge(code, description))
+	c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": message})
+}
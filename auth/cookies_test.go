@@ -0,0 +1,92 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestSetAuthCookies(t *testing.T) {
+	t.Setenv("AUTH_COOKIES", "true")
+	t.Setenv("AUTH_COOKIE_SECURE", "false")
+	gin.SetMode(gin.TestMode)
+
+	recorder := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(recorder)
+	if err := setAuthCookies(c, &TokenPair{AccessToken: "access", RefreshToken: "refresh", ExpiresIn: 900}); err != nil {
+		t.Fatal(err)
+	}
+
+	cookies := make(map[string]*http.Cookie)
+	for _, cookie := range recorder.Result().Cookies() {
+		cookies[cookie.Name] = cookie
+	}
+	for _, name := range []string{accessCookie, refreshCookie} {
+		if cookie := cookies[name]; cookie == nil || !cookie.HttpOnly {
+			t.Errorf("%s cookie must be set and HttpOnly: %+v", name, cookie)
+		}
+	}
+	if cookie := cookies[csrfCookie]; cookie == nil || cookie.HttpOnly || len(cookie.Value) < 32 {
+		t.Errorf("csrf cookie must be readable by scripts and random: %+v", cookie)
+	}
+}
+
+func TestCookieAuthRequiresCSRFToken(t *testing.T) {
+	openTestDB(t)
+	t.Setenv("AUTH_COOKIES", "true")
+	ctx := context.Background()
+
+	user, err := RegisterUser(ctx, "Мария", "user@example.com", "password123")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pair, err := IssueTokens(ctx, user, "test", "127.0.0.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	ok := func(c *gin.Context) { c.Status(http.StatusOK) }
+	router.GET("/profile", AuthMiddleware(), ok)
+	router.PATCH("/profile", AuthMiddleware(), ok)
+
+	send := func(method, csrfHeaderValue string, withCookies bool) int {
+		recorder := httptest.NewRecorder()
+		req := httptest.NewRequest(method, "/profile", strings.NewReader("{}"))
+		if withCookies {
+			req.AddCookie(&http.Cookie{Name: accessCookie, Value: pair.AccessToken})
+			req.AddCookie(&http.Cookie{Name: csrfCookie, Value: "csrf-secret"})
+		} else {
+			req.Header.Set("Authorization", "Bearer "+pair.AccessToken)
+		}
+		if csrfHeaderValue != "" {
+			req.Header.Set(csrfHeader, csrfHeaderValue)
+		}
+		router.ServeHTTP(recorder, req)
+		return recorder.Code
+	}
+
+	cases := []struct {
+		name        string
+		method      string
+		csrf        string
+		withCookies bool
+		want        int
+	}{
+		{"safe method with cookie", http.MethodGet, "", true, http.StatusOK},
+		{"unsafe method without header", http.MethodPatch, "", true, http.StatusForbidden},
+		{"unsafe method with wrong header", http.MethodPatch, "forged", true, http.StatusForbidden},
+		{"unsafe method with matching header", http.MethodPatch, "csrf-secret", true, http.StatusOK},
+		{"bearer token needs no header", http.MethodPatch, "", false, http.StatusOK},
+	}
+	for _, tc := range cases {
+		if got := send(tc.method, tc.csrf, tc.withCookies); got != tc.want {
+			t.Errorf("%s: status %d, want %d", tc.name, got, tc.want)
+		}
+	}
+}
@@ -1,9 +1,13 @@
 package auth
 
 import (
+	"errors"
+	"log"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
+	"github.com/planer/backend/internal/audit"
+	"github.com/planer/backend/internal/database"
 )
 
 type RegisterRequest struct {
@@ -16,9 +20,58 @@ type LoginRequest struct {
 	Email    string `json:"email" binding:"required,email"`
 	Password string `json:"password" binding:"required"`
 }
-type AuthResponse struct {
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+type VerifyEmailRequest struct {
 	Token string `json:"token"`
-	User  User   `json:"user"`
+}
+
+type ForgotPasswordRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+type ResetPasswordRequest struct {
+	Token    string `json:"token" binding:"required"`
+	Password string `json:"password" binding:"required,min=8"`
+}
+
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password" binding:"required"`
+	NewPassword     string `json:"new_password" binding:"required,min=8"`
+}
+
+type TokenResponse struct {
+	Token        string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int    `json:"expires_in"`
+}
+
+type AuthResponse struct {
+	TokenResponse
+	User User `json:"user"`
+}
+
+func newTokenResponse(pair *TokenPair) TokenResponse {
+	return TokenResponse{
+		Token:        pair.AccessToken,
+		RefreshToken: pair.RefreshToken,
+		ExpiresIn:    pair.ExpiresIn,
+	}
+}
+
+func startSession(c *gin.Context, user *User) (*TokenPair, bool) {
+	pair, err := IssueTokens(c.Request.Context(), user, c.Request.UserAgent(), c.ClientIP())
+	if err == nil {
+		err = setAuthCookies(c, pair)
+	}
+	if err != nil {
+		log.Printf("Ошибка при создании сессии: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Не удалось создать токен"})
+		return nil, false
+	}
+	return pair, true
 }
 
 func RegisterHandler(c *gin.Context) {
@@ -28,33 +81,31 @@ func RegisterHandler(c *gin.Context) {
 		return
 	}
 
-	err := RegisterUser(req.Name, req.Email, req.Password)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	user, err := RegisterUser(c.Request.Context(), req.Name, req.Email, req.Password)
+	if errors.Is(err, ErrEmailExists) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
 		return
 	}
-
-	token, err := AuthenticateUser(req.Email, req.Password)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Не удалось создать токен"})
+		log.Printf("Ошибка при регистрации пользователя: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Не удалось зарегистрировать пользователя"})
 		return
 	}
 
-	claims, err := ValidateToken(token)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Не удалось получить данные пользователя"})
-		return
+	audit.Record(c, audit.Entry{ActorID: user.ID, Action: audit.ActionRegister, TargetType: audit.TargetUser, TargetID: user.ID})
+
+	if _, err := SendVerificationEmail(c.Request.Context(), user); err != nil {
+		log.Printf("Ошибка при отправке письма подтверждения: %v", err)
 	}
 
-	user, err := GetUserByID(claims.UserID)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Не удалось получить данные пользователя"})
+	pair, ok := startSession(c, user)
+	if !ok {
 		return
 	}
 
 	c.JSON(http.StatusCreated, AuthResponse{
-		Token: token,
-		User:  *user,
+		TokenResponse: newTokenResponse(pair),
+		User:          *user,
 	})
 }
 
@@ -65,27 +116,53 @@ func LoginHandler(c *gin.Context) {
 		return
 	}
 
-	token, err := AuthenticateUser(req.Email, req.Password)
+	throttle := activeThrottle()
+	retryAfter, err := throttle.Check(c.Request.Context(), req.Email, c.ClientIP())
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		log.Printf("Ошибка при проверке ограничения входа: %v", err)
+	}
+	if retryAfter > 0 {
+		audit.Record(c, audit.Entry{Action: audit.ActionLoginThrottled, Details: map[string]interface{}{"email": req.Email}})
+		c.Header("Retry-After", retryAfterSeconds(retryAfter))
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": "Слишком много попыток входа, повторите позже"})
 		return
 	}
 
-	claims, err := ValidateToken(token)
+	user, err := AuthenticateUser(c.Request.Context(), req.Email, req.Password)
+	if errors.Is(err, ErrUserBlocked) {
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	}
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Не удалось получить данные пользователя"})
+		audit.Record(c, audit.Entry{Action: audit.ActionLoginFailed, Details: map[string]interface{}{"email": req.Email}})
+		lockouts, throttleErr := throttle.RecordFailure(c.Request.Context(), req.Email, c.ClientIP())
+		if throttleErr != nil {
+			log.Printf("Ошибка при учёте неудачного входа: %v", throttleErr)
+		}
+		for _, lockout := range lockouts {
+			audit.Record(c, audit.Entry{Action: audit.ActionLoginLocked, Details: map[string]interface{}{
+				"email":   req.Email,
+				"scope":   lockout.Scope,
+				"seconds": int(lockout.Duration.Seconds()),
+			}})
+		}
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
 		return
 	}
+	if err := throttle.RecordSuccess(c.Request.Context(), req.Email); err != nil {
+		log.Printf("Ошибка при сбросе счётчика входа: %v", err)
+	}
 
-	user, err := GetUserByID(claims.UserID)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Не удалось получить данные пользователя"})
+	pair, ok := startSession(c, user)
+	if !ok {
 		return
 	}
 
+	audit.Record(c, audit.Entry{ActorID: user.ID, Action: audit.ActionLogin, TargetType: audit.TargetUser, TargetID: user.ID})
+
 	c.JSON(http.StatusOK, AuthResponse{
-		Token: token,
-		User:  *user,
+		TokenResponse: newTokenResponse(pair),
+		User:          *user,
 	})
 }
 
@@ -96,8 +173,9 @@ func GetProfileHandler(c *gin.Context) {
 		return
 	}
 
-	user, err := GetUserByID(userID.(int))
+	user, err := GetUserByID(c.Request.Context(), userID.(int))
 	if err != nil {
+		log.Printf("Ошибка при получении профиля: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Не удалось получить данные пользователя"})
 		return
 	}
@@ -105,23 +183,331 @@ func GetProfileHandler(c *gin.Context) {
 	c.JSON(http.StatusOK, user)
 }
 
-func AuthMiddleware() gin.HandlerFunc {
+func RefreshHandler(c *gin.Context) {
+	var req RefreshRequest
+	c.ShouldBindJSON(&req)
+
+	if req.RefreshToken == "" && cookieConfig().enabled {
+		req.RefreshToken, _ = c.Cookie(refreshCookie)
+		if req.RefreshToken != "" && !validCSRF(c) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Неверный CSRF-токен"})
+			return
+		}
+	}
+	if req.RefreshToken == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Не указан refresh-токен"})
+		return
+	}
+
+	pair, userID, err := RefreshTokens(c.Request.Context(), req.RefreshToken)
+	if errors.Is(err, ErrRefreshTokenReused) {
+		audit.Record(c, audit.Entry{ActorID: userID, Action: audit.ActionTokenReuse, TargetType: audit.TargetUser, TargetID: userID})
+		clearAuthCookies(c)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+	if errors.Is(err, ErrRefreshTokenInvalid) {
+		clearAuthCookies(c)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		log.Printf("Ошибка при обновлении токена: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Не удалось обновить токен"})
+		return
+	}
+	if err := setAuthCookies(c, pair); err != nil {
+		log.Printf("Ошибка при установке cookie: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Не удалось обновить токен"})
+		return
+	}
+
+	c.JSON(http.StatusOK, newTokenResponse(pair))
+}
+
+func LogoutHandler(c *gin.Context) {
+	userID := c.GetInt("userID")
+	if _, err := RevokeSession(c.Request.Context(), userID, c.GetString("sessionID")); err != nil {
+		log.Printf("Ошибка при завершении сессии: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Не удалось завершить сессию"})
+		return
+	}
+
+	audit.Record(c, audit.Entry{Action: audit.ActionLogout, TargetType: audit.TargetUser, TargetID: userID})
+	clearAuthCookies(c)
+	c.Status(http.StatusNoContent)
+}
+
+func LogoutAllHandler(c *gin.Context) {
+	userID := c.GetInt("userID")
+	if err := RevokeUserSessions(c.Request.Context(), userID); err != nil {
+		log.Printf("Ошибка при завершении сессий: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Не удалось завершить сессии"})
+		return
+	}
+
+	audit.Record(c, audit.Entry{Action: audit.ActionLogoutAll, TargetType: audit.TargetUser, TargetID: userID})
+	clearAuthCookies(c)
+	c.Status(http.StatusNoContent)
+}
+
+func VerifyEmailHandler(c *gin.Context) {
+	token := c.Query("token")
+	if token == "" {
+		var req VerifyEmailRequest
+		c.ShouldBindJSON(&req)
+		token = req.Token
+	}
+	if token == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Не указан токен подтверждения"})
+		return
+	}
+
+	user, err := VerifyEmail(c.Request.Context(), token)
+	if errors.Is(err, ErrEmailAlreadyVerified) {
+		c.JSON(http.StatusOK, gin.H{"user": user})
+		return
+	}
+	if errors.Is(err, ErrVerificationInvalid) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if errors.Is(err, ErrEmailExists) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		log.Printf("Ошибка при подтверждении email: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Не удалось подтвердить email"})
+		return
+	}
+
+	audit.Record(c, audit.Entry{ActorID: user.ID, Action: audit.ActionEmailVerified, TargetType: audit.TargetUser, TargetID: user.ID})
+	c.JSON(http.StatusOK, gin.H{"user": user})
+}
+
+func ResendVerificationHandler(c *gin.Context) {
+	user, err := GetUserByID(c.Request.Context(), c.GetInt("userID"))
+	if err != nil {
+		log.Printf("Ошибка при получении пользователя: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Не удалось отправить письмо"})
+		return
+	}
+
+	retryAfter, err := SendVerificationEmail(c.Request.Context(), user)
+	if errors.Is(err, ErrEmailAlreadyVerified) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	if errors.Is(err, ErrVerificationThrottled) {
+		c.Header("Retry-After", retryAfterSeconds(retryAfter))
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		log.Printf("Ошибка при отправке письма подтверждения: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Не удалось отправить письмо"})
+		return
+	}
+
+	c.Status(http.StatusAccepted)
+}
+
+func ForgotPasswordHandler(c *gin.Context) {
+	var req ForgotPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Некорректные данные: " + err.Error()})
+		return
+	}
+
+	if err := RequestPasswordReset(c.Request.Context(), req.Email); err != nil {
+		log.Printf("Ошибка при запросе сброса пароля: %v", err)
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"message": "Если такой адрес зарегистрирован, на него отправлено письмо со ссылкой для сброса пароля"})
+}
+
+func ResetPasswordHandler(c *gin.Context) {
+	var req ResetPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Некорректные данные: " + err.Error()})
+		return
+	}
+
+	userID, err := ResetPassword(c.Request.Context(), req.Token, req.Password)
+	if errors.Is(err, ErrResetTokenInvalid) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		log.Printf("Ошибка при сбросе пароля: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Не удалось сбросить пароль"})
+		return
+	}
+
+	audit.Record(c, audit.Entry{ActorID: userID, Action: audit.ActionPasswordReset, TargetType: audit.TargetUser, TargetID: userID})
+	clearAuthCookies(c)
+	c.Status(http.StatusNoContent)
+}
+
+func ChangePasswordHandler(c *gin.Context) {
+	var req ChangePasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Некорректные данные: " + err.Error()})
+		return
+	}
+
+	userID := c.GetInt("userID")
+	err := ChangePassword(c.Request.Context(), userID, req.CurrentPassword, req.NewPassword)
+	if errors.Is(err, ErrInvalidPassword) {
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		log.Printf("Ошибка при смене пароля: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Не удалось сменить пароль"})
+		return
+	}
+
+	audit.Record(c, audit.Entry{Action: audit.ActionPasswordChange, TargetType: audit.TargetUser, TargetID: userID})
+
+	user, err := GetUserByID(c.Request.Context(), userID)
+	if err != nil {
+		log.Printf("Ошибка при получении пользователя: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Не удалось создать токен"})
+		return
+	}
+	pair, ok := startSession(c, user)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, newTokenResponse(pair))
+}
+
+func AuthMiddleware(scopes ...string) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		authHeader := c.GetHeader("Authorization")
-		if authHeader == "" {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Требуется авторизация"})
-			c.Abort()
+		tokenString, fromCookie, err := extractToken(c)
+		if err != nil {
+			c.Header("WWW-Authenticate", challenge("invalid_request", err.Error()))
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Некорректный заголовок Authorization"})
+			return
+		}
+		if tokenString == "" {
+			abortUnauthorized(c, "", "", "Требуется авторизация")
+			return
+		}
+		if fromCookie && !validCSRF(c) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Неверный CSRF-токен"})
+			return
+		}
+
+		if IsAPIKey(tokenString) {
+			principal, err := authenticateAPIKey(c.Request.Context(), tokenString)
+			if errors.Is(err, ErrAPIKeyInvalid) {
+				abortUnauthorized(c, "invalid_token", "api key is invalid, revoked or expired", err.Error())
+				return
+			}
+			if err != nil {
+				log.Printf("Ошибка при проверке API-ключа: %v", err)
+				c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Не удалось проверить API-ключ"})
+				return
+			}
+			if len(scopes) == 0 {
+				c.Header("WWW-Authenticate", challenge("insufficient_scope", "api keys are not accepted here"))
+				c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Этот раздел доступен только после входа в аккаунт"})
+				return
+			}
+			for _, scope := range scopes {
+				if !HasScope(principal.scopes, scope) {
+					abortInsufficientScope(c, scope)
+					return
+				}
+			}
+
+			c.Set("userID", principal.userID)
+			c.Set("role", principal.role)
+			c.Set("apiKeyID", principal.keyID)
+			c.Set("apiKeyScopes", principal.scopes)
+			c.Next()
 			return
 		}
 
-		claims, err := ValidateToken(authHeader)
+		claims, err := ValidateToken(tokenString)
 		if err != nil {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Невалидный токен"})
-			c.Abort()
+			abortUnauthorized(c, "invalid_token", "token is invalid or expired", "Невалидный токен")
 			return
 		}
 
+		if !database.MockMode {
+			active, err := sessionActive(c.Request.Context(), claims.UserID, claims.SessionID)
+			if err != nil {
+				log.Printf("Ошибка при проверке сессии: %v", err)
+				c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Не удалось проверить сессию"})
+				return
+			}
+			if !active {
+				abortUnauthorized(c, "invalid_token", "session has been revoked", "Сессия завершена")
+				return
+			}
+		}
+
 		c.Set("userID", claims.UserID)
+		c.Set("role", claims.Role)
+		c.Set("sessionID", claims.SessionID)
+		c.Next()
+	}
+}
+
+func AdminMiddleware() gin.HandlerFunc {
+	return RequireRole(RoleAdmin)
+}
+
+func RequireScope(scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		value, ok := c.Get("apiKeyScopes")
+		if !ok {
+			c.Next()
+			return
+		}
+
+		scopes, _ := value.([]string)
+		if !HasScope(scopes, scope) {
+			abortInsufficientScope(c, scope)
+			return
+		}
+
+		c.Next()
+	}
+}
+
+func abortInsufficientScope(c *gin.Context, scope string) {
+	c.Header("WWW-Authenticate", challenge("insufficient_scope", "api key lacks scope "+scope))
+	c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "У API-ключа нет доступа: " + scope})
+}
+
+func VerifiedMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !RequireVerifiedForAI() {
+			c.Next()
+			return
+		}
+
+		user, err := GetUserByID(c.Request.Context(), c.GetInt("userID"))
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Требуется авторизация"})
+			return
+		}
+		if !user.EmailVerified {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Подтвердите email, чтобы пользоваться генерацией"})
+			return
+		}
+
 		c.Next()
 	}
 }
+
+func JWKSHandler(c *gin.Context) {
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, activeKeys().jwks())
+}
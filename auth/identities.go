@@ -0,0 +1,111 @@
+package auth
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/planer/backend/internal/database"
+)
+
+func linkIdentity(ctx context.Context, provider string, claims *IDTokenClaims) (*User, bool, error) {
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
+
+	var userID int
+	err := database.DB.QueryRowContext(ctx,
+		"SELECT user_id FROM user_identities WHERE provider = $1 AND subject = $2",
+		provider, claims.Subject,
+	).Scan(&userID)
+	if err == nil {
+		if _, err := database.DB.ExecContext(ctx,
+			"UPDATE user_identities SET last_login_at = CURRENT_TIMESTAMP, email = $1 WHERE provider = $2 AND subject = $3",
+			claims.Email, provider, claims.Subject,
+		); err != nil {
+			return nil, false, err
+		}
+		user, err := GetUserByID(ctx, userID)
+		return user, false, err
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return nil, false, err
+	}
+
+	email := strings.TrimSpace(claims.Email)
+	if email == "" {
+		return nil, false, ErrOIDCNoEmail
+	}
+	verified := bool(claims.EmailVerified)
+
+	tx, err := database.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, false, err
+	}
+	defer tx.Rollback()
+
+	created := false
+	user, err := scanUser(tx.QueryRowContext(ctx,
+		"SELECT "+userColumns+" FROM users WHERE LOWER(email) = LOWER($1)",
+		email,
+	))
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		name := strings.TrimSpace(claims.Name)
+		if name == "" {
+			name = strings.SplitN(email, "@", 2)[0]
+		}
+		verifiedAt := sql.NullTime{}
+		if verified {
+			verifiedAt = sql.NullTime{Time: time.Now().UTC(), Valid: true}
+		}
+		user, err = scanUser(tx.QueryRowContext(ctx,
+			"INSERT INTO users (name, email, password_hash, email_verified_at) VALUES ($1, $2, '', $3) RETURNING "+userColumns,
+			name, email, verifiedAt,
+		))
+		if err != nil {
+			return nil, false, err
+		}
+		created = true
+	case err != nil:
+		return nil, false, err
+	case !verified:
+		return nil, false, ErrOIDCEmailUnverified
+	case !user.EmailVerified:
+		if err := claimUnverifiedUser(ctx, tx, user.ID); err != nil {
+			return nil, false, err
+		}
+	}
+
+	if _, err := tx.ExecContext(ctx,
+		"INSERT INTO user_identities (provider, subject, user_id, email) VALUES ($1, $2, $3, $4)",
+		provider, claims.Subject, user.ID, email,
+	); err != nil {
+		return nil, false, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, false, err
+	}
+
+	if !created {
+		user, err = GetUserByID(ctx, user.ID)
+	}
+	return user, created, err
+}
+
+func claimUnverifiedUser(ctx context.Context, tx *sql.Tx, userID int) error {
+	if _, err := tx.ExecContext(ctx,
+		"UPDATE users SET password_hash = '', email_verified_at = CURRENT_TIMESTAMP, updated_at = CURRENT_TIMESTAMP WHERE id = $1",
+		userID,
+	); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx,
+		"UPDATE api_keys SET revoked_at = CURRENT_TIMESTAMP WHERE user_id = $1 AND revoked_at IS NULL",
+		userID,
+	); err != nil {
+		return err
+	}
+	return revokeSessionsTx(ctx, tx, userID)
+}
@@ -0,0 +1,335 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"math/big"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+type KeyConfig struct {
+	KID            string `json:"kid"`
+	Algorithm      string `json:"alg"`
+	Secret         string `json:"secret,omitempty"`
+	SecretFile     string `json:"secret_file,omitempty"`
+	PrivateKeyFile string `json:"private_key_file,omitempty"`
+	PublicKeyFile  string `json:"public_key_file,omitempty"`
+}
+
+type KeysConfig struct {
+	SigningKID string      `json:"signing_kid"`
+	Keys       []KeyConfig `json:"keys"`
+}
+
+type signingKey struct {
+	kid       string
+	method    jwt.SigningMethod
+	signKey   interface{}
+	verifyKey interface{}
+}
+
+type keySet struct {
+	signing *signingKey
+	keys    map[string]*signingKey
+	methods []string
+}
+
+type JWK struct {
+	KeyType   string `json:"kty"`
+	KeyID     string `json:"kid"`
+	Algorithm string `json:"alg"`
+	Use       string `json:"use"`
+	N         string `json:"n,omitempty"`
+	E         string `json:"e,omitempty"`
+	Curve     string `json:"crv,omitempty"`
+	X         string `json:"x,omitempty"`
+	Y         string `json:"y,omitempty"`
+}
+
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+var (
+	keysOnce sync.Once
+	keys     *keySet
+)
+
+func InitKeys() {
+	keysOnce.Do(func() {
+		config, err := loadKeysConfig()
+		if err != nil {
+			log.Fatalf("Не удалось загрузить ключи подписи JWT: %v", err)
+		}
+		keys, err = buildKeySet(config)
+		if err != nil {
+			log.Fatalf("Не удалось загрузить ключи подписи JWT: %v", err)
+		}
+		log.Printf("Загружено ключей JWT: %d, подпись ключом %s (%s)",
+			len(keys.keys), keys.signing.kid, keys.signing.method.Alg())
+	})
+}
+
+func activeKeys() *keySet {
+	InitKeys()
+	return keys
+}
+
+func loadKeysConfig() (KeysConfig, error) {
+	var config KeysConfig
+
+	if path := os.Getenv("JWT_KEYS_FILE"); path != "" {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return config, err
+		}
+		if err := json.Unmarshal(data, &config); err != nil {
+			return config, fmt.Errorf("%s: %v", path, err)
+		}
+		return config, nil
+	}
+
+	key := KeyConfig{
+		KID:            getEnv("JWT_KEY_ID", "default"),
+		Algorithm:      getEnv("JWT_ALGORITHM", "HS256"),
+		Secret:         os.Getenv("JWT_SECRET"),
+		SecretFile:     os.Getenv("JWT_SECRET_FILE"),
+		PrivateKeyFile: os.Getenv("JWT_PRIVATE_KEY_FILE"),
+	}
+	if key.Algorithm == "HS256" && key.Secret == "" && key.SecretFile == "" {
+		secret := make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			return config, err
+		}
+		key.Secret = base64.RawURLEncoding.EncodeToString(secret)
+		log.Println("JWT_SECRET не задан, используем случайный ключ: токены перестанут действовать после перезапуска")
+	}
+
+	config.SigningKID = key.KID
+	config.Keys = []KeyConfig{key}
+	return config, nil
+}
+
+func buildKeySet(config KeysConfig) (*keySet, error) {
+	set := &keySet{keys: make(map[string]*signingKey)}
+	seenMethods := make(map[string]bool)
+
+	for _, kc := range config.Keys {
+		if kc.KID == "" {
+			return nil, errors.New("у ключа не указан kid")
+		}
+		if _, exists := set.keys[kc.KID]; exists {
+			return nil, fmt.Errorf("ключ %s указан дважды", kc.KID)
+		}
+
+		key, err := loadKey(kc)
+		if err != nil {
+			return nil, fmt.Errorf("ключ %s: %v", kc.KID, err)
+		}
+		set.keys[kc.KID] = key
+		if !seenMethods[key.method.Alg()] {
+			seenMethods[key.method.Alg()] = true
+			set.methods = append(set.methods, key.method.Alg())
+		}
+	}
+
+	set.signing = set.keys[config.SigningKID]
+	if set.signing == nil {
+		return nil, fmt.Errorf("ключ подписи %q не найден", config.SigningKID)
+	}
+	if set.signing.signKey == nil {
+		return nil, fmt.Errorf("для ключа подписи %s не задан закрытый ключ", config.SigningKID)
+	}
+	return set, nil
+}
+
+func loadKey(kc KeyConfig) (*signingKey, error) {
+	key := &signingKey{kid: kc.KID}
+
+	switch kc.Algorithm {
+	case "HS256":
+		secret := []byte(kc.Secret)
+		if kc.SecretFile != "" {
+			data, err := ioutil.ReadFile(kc.SecretFile)
+			if err != nil {
+				return nil, err
+			}
+			secret = []byte(strings.TrimSpace(string(data)))
+		}
+		if len(secret) < 32 {
+			return nil, errors.New("секрет HS256 должен быть не короче 32 байт")
+		}
+		key.method = jwt.SigningMethodHS256
+		key.signKey = secret
+		key.verifyKey = secret
+
+	case "RS256":
+		key.method = jwt.SigningMethodRS256
+		if kc.PrivateKeyFile != "" {
+			data, err := ioutil.ReadFile(kc.PrivateKeyFile)
+			if err != nil {
+				return nil, err
+			}
+			private, err := jwt.ParseRSAPrivateKeyFromPEM(data)
+			if err != nil {
+				return nil, err
+			}
+			key.signKey = private
+			key.verifyKey = &private.PublicKey
+		} else if kc.PublicKeyFile != "" {
+			data, err := ioutil.ReadFile(kc.PublicKeyFile)
+			if err != nil {
+				return nil, err
+			}
+			public, err := jwt.ParseRSAPublicKeyFromPEM(data)
+			if err != nil {
+				return nil, err
+			}
+			key.verifyKey = public
+		}
+
+	case "EdDSA":
+		key.method = jwt.SigningMethodEdDSA
+		if kc.PrivateKeyFile != "" {
+			data, err := ioutil.ReadFile(kc.PrivateKeyFile)
+			if err != nil {
+				return nil, err
+			}
+			private, err := jwt.ParseEdPrivateKeyFromPEM(data)
+			if err != nil {
+				return nil, err
+			}
+			edPrivate, ok := private.(ed25519.PrivateKey)
+			if !ok {
+				return nil, errors.New("ожидался ключ Ed25519")
+			}
+			key.signKey = edPrivate
+			key.verifyKey = edPrivate.Public()
+		} else if kc.PublicKeyFile != "" {
+			data, err := ioutil.ReadFile(kc.PublicKeyFile)
+			if err != nil {
+				return nil, err
+			}
+			public, err := jwt.ParseEdPublicKeyFromPEM(data)
+			if err != nil {
+				return nil, err
+			}
+			key.verifyKey = public
+		}
+
+	default:
+		return nil, fmt.Errorf("неподдерживаемый алгоритм %q", kc.Algorithm)
+	}
+
+	if key.verifyKey == nil {
+		return nil, errors.New("не задан ни закрытый, ни открытый ключ")
+	}
+	return key, nil
+}
+
+func (s *keySet) keyFunc(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	key, ok := s.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("неизвестный ключ подписи %q", kid)
+	}
+	if token.Method.Alg() != key.method.Alg() {
+		return nil, fmt.Errorf("алгоритм %s не соответствует ключу %s", token.Method.Alg(), kid)
+	}
+	return key.verifyKey, nil
+}
+
+func (s *keySet) sign(claims jwt.Claims) (string, error) {
+	token := jwt.NewWithClaims(s.signing.method, claims)
+	token.Header["kid"] = s.signing.kid
+	return token.SignedString(s.signing.signKey)
+}
+
+func (s *keySet) parse(tokenString string, claims jwt.Claims, audience string) error {
+	token, err := jwt.ParseWithClaims(tokenString, claims, s.keyFunc,
+		jwt.WithValidMethods(s.methods),
+		jwt.WithIssuer(tokenIssuer()),
+		jwt.WithAudience(audience),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+		jwt.WithLeeway(30*time.Second),
+	)
+	if err != nil {
+		return err
+	}
+	if !token.Valid {
+		return errors.New("невалидный токен")
+	}
+	return nil
+}
+
+func (s *keySet) jwks() JWKS {
+	kids := make([]string, 0, len(s.keys))
+	for kid := range s.keys {
+		kids = append(kids, kid)
+	}
+	sort.Strings(kids)
+
+	set := JWKS{Keys: make([]JWK, 0, len(kids))}
+	for _, kid := range kids {
+		key := s.keys[kid]
+		switch public := key.verifyKey.(type) {
+		case *rsa.PublicKey:
+			set.Keys = append(set.Keys, JWK{
+				KeyType:   "RSA",
+				KeyID:     kid,
+				Algorithm: key.method.Alg(),
+				Use:       "sig",
+				N:         base64.RawURLEncoding.EncodeToString(public.N.Bytes()),
+				E:         base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes()),
+			})
+		case ed25519.PublicKey:
+			set.Keys = append(set.Keys, JWK{
+				KeyType:   "OKP",
+				KeyID:     kid,
+				Algorithm: key.method.Alg(),
+				Use:       "sig",
+				Curve:     "Ed25519",
+				X:         base64.RawURLEncoding.EncodeToString(public),
+			})
+		}
+	}
+	return set
+}
+
+func tokenIssuer() string {
+	return getEnv("JWT_ISSUER", "planer")
+}
+
+func tokenAudience() string {
+	return getEnv("JWT_AUDIENCE", "planer-api")
+}
+
+func getEnv(key, defaultValue string) string {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	return value
+}
+
+func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
+	value, err := time.ParseDuration(os.Getenv(key))
+	if err != nil {
+		return defaultValue
+	}
+	return value
+}
@@ -0,0 +1,389 @@
+package auth
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"math/big"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var (
+	ErrOIDCProviderNotFound = errors.New("провайдер входа не найден")
+	ErrOIDCInvalidState     = errors.New("недействительный параметр state")
+	ErrOIDCInvalidIDToken   = errors.New("недействительный ID-токен провайдера")
+	ErrOIDCNoEmail          = errors.New("провайдер не передал email")
+	ErrOIDCEmailUnverified  = errors.New("email не подтверждён провайдером, привязка к существующему аккаунту невозможна")
+)
+
+type OIDCProviderConfig struct {
+	Name                  string   `json:"name"`
+	Title                 string   `json:"title"`
+	Issuer                string   `json:"issuer"`
+	ClientID              string   `json:"client_id"`
+	ClientSecret          string   `json:"client_secret"`
+	Scopes                []string `json:"scopes,omitempty"`
+	RedirectURL           string   `json:"redirect_url,omitempty"`
+	AuthorizationEndpoint string   `json:"authorization_endpoint,omitempty"`
+	TokenEndpoint         string   `json:"token_endpoint,omitempty"`
+	JWKSURI               string   `json:"jwks_uri,omitempty"`
+}
+
+type OIDCProviderInfo struct {
+	Name  string `json:"name"`
+	Title string `json:"title"`
+}
+
+type oidcMetadata struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+type oidcProvider struct {
+	config OIDCProviderConfig
+
+	mu         sync.Mutex
+	metadata   *oidcMetadata
+	metadataAt time.Time
+	keys       map[string]interface{}
+	keysAt     time.Time
+}
+
+type flexibleBool bool
+
+func (b *flexibleBool) UnmarshalJSON(data []byte) error {
+	value := strings.Trim(string(data), `"`)
+	*b = flexibleBool(value == "true" || value == "1")
+	return nil
+}
+
+type IDTokenClaims struct {
+	Nonce         string       `json:"nonce"`
+	Email         string       `json:"email"`
+	EmailVerified flexibleBool `json:"email_verified"`
+	Name          string       `json:"name"`
+	jwt.RegisteredClaims
+}
+
+const (
+	oidcCacheTTL      = time.Hour
+	oidcKeyRefreshGap = time.Minute
+)
+
+var (
+	oidcOnce      sync.Once
+	oidcProviders map[string]*oidcProvider
+	oidcClient    = &http.Client{Timeout: 10 * time.Second}
+)
+
+func InitOIDC() {
+	oidcOnce.Do(func() {
+		oidcProviders = make(map[string]*oidcProvider)
+
+		path := os.Getenv("OIDC_PROVIDERS_FILE")
+		if path == "" {
+			return
+		}
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			log.Fatalf("Не удалось загрузить провайдеров OIDC: %v", err)
+		}
+		var configs []OIDCProviderConfig
+		if err := json.Unmarshal(data, &configs); err != nil {
+			log.Fatalf("Не удалось загрузить провайдеров OIDC: %s: %v", path, err)
+		}
+
+		for _, config := range configs {
+			if config.Name == "" || config.Issuer == "" || config.ClientID == "" {
+				log.Fatalf("У провайдера OIDC %q не заданы name, issuer или client_id", config.Name)
+			}
+			if config.Title == "" {
+				config.Title = config.Name
+			}
+			if len(config.Scopes) == 0 {
+				config.Scopes = []string{"openid", "email", "profile"}
+			}
+			if config.RedirectURL == "" {
+				config.RedirectURL = strings.TrimRight(getEnv("API_BASE_URL", "http://localhost:8080"), "/") +
+					"/api/auth/oidc/" + config.Name + "/callback"
+			}
+			oidcProviders[config.Name] = &oidcProvider{config: config}
+		}
+		log.Printf("Загружено провайдеров OIDC: %d", len(oidcProviders))
+	})
+}
+
+func lookupOIDCProvider(name string) (*oidcProvider, error) {
+	InitOIDC()
+	provider, ok := oidcProviders[name]
+	if !ok {
+		return nil, ErrOIDCProviderNotFound
+	}
+	return provider, nil
+}
+
+func ListOIDCProviders() []OIDCProviderInfo {
+	InitOIDC()
+	providers := make([]OIDCProviderInfo, 0, len(oidcProviders))
+	for _, provider := range oidcProviders {
+		providers = append(providers, OIDCProviderInfo{Name: provider.config.Name, Title: provider.config.Title})
+	}
+	sort.Slice(providers, func(i, j int) bool { return providers[i].Name < providers[j].Name })
+	return providers
+}
+
+func (p *oidcProvider) discover(ctx context.Context) (*oidcMetadata, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.metadata != nil && time.Since(p.metadataAt) < oidcCacheTTL {
+		return p.metadata, nil
+	}
+
+	metadata := &oidcMetadata{
+		Issuer:                p.config.Issuer,
+		AuthorizationEndpoint: p.config.AuthorizationEndpoint,
+		TokenEndpoint:         p.config.TokenEndpoint,
+		JWKSURI:               p.config.JWKSURI,
+	}
+	if metadata.AuthorizationEndpoint == "" || metadata.TokenEndpoint == "" || metadata.JWKSURI == "" {
+		var discovered oidcMetadata
+		discoveryURL := strings.TrimRight(p.config.Issuer, "/") + "/.well-known/openid-configuration"
+		if err := fetchJSON(ctx, discoveryURL, &discovered); err != nil {
+			return nil, err
+		}
+		if discovered.Issuer != p.config.Issuer {
+			return nil, fmt.Errorf("issuer %q не совпадает с настроенным %q", discovered.Issuer, p.config.Issuer)
+		}
+		if metadata.AuthorizationEndpoint == "" {
+			metadata.AuthorizationEndpoint = discovered.AuthorizationEndpoint
+		}
+		if metadata.TokenEndpoint == "" {
+			metadata.TokenEndpoint = discovered.TokenEndpoint
+		}
+		if metadata.JWKSURI == "" {
+			metadata.JWKSURI = discovered.JWKSURI
+		}
+	}
+
+	p.metadata = metadata
+	p.metadataAt = time.Now()
+	return metadata, nil
+}
+
+func (p *oidcProvider) publicKey(ctx context.Context, kid string) (interface{}, error) {
+	metadata, err := p.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	stale := p.keys == nil || time.Since(p.keysAt) > oidcCacheTTL
+	_, known := p.keys[kid]
+	if stale || (!known && time.Since(p.keysAt) > oidcKeyRefreshGap) {
+		keys, err := fetchJWKS(ctx, metadata.JWKSURI)
+		if err != nil {
+			return nil, err
+		}
+		p.keys = keys
+		p.keysAt = time.Now()
+	}
+
+	key, ok := p.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("неизвестный ключ провайдера %q", kid)
+	}
+	return key, nil
+}
+
+func (p *oidcProvider) authCodeURL(ctx context.Context, state, nonce, verifier string) (string, error) {
+	metadata, err := p.discover(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	challenge := sha256.Sum256([]byte(verifier))
+	params := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {p.config.ClientID},
+		"redirect_uri":          {p.config.RedirectURL},
+		"scope":                 {strings.Join(p.config.Scopes, " ")},
+		"state":                 {state},
+		"nonce":                 {nonce},
+		"code_challenge":        {base64.RawURLEncoding.EncodeToString(challenge[:])},
+		"code_challenge_method": {"S256"},
+	}
+
+	separator := "?"
+	if strings.Contains(metadata.AuthorizationEndpoint, "?") {
+		separator = "&"
+	}
+	return metadata.AuthorizationEndpoint + separator + params.Encode(), nil
+}
+
+func (p *oidcProvider) exchange(ctx context.Context, code, verifier string) (string, error) {
+	metadata, err := p.discover(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {p.config.RedirectURL},
+		"client_id":     {p.config.ClientID},
+		"client_secret": {p.config.ClientSecret},
+		"code_verifier": {verifier},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, metadata.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := oidcClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		IDToken          string `json:"id_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", fmt.Errorf("некорректный ответ token endpoint (%d): %v", resp.StatusCode, err)
+	}
+	if result.Error != "" {
+		return "", fmt.Errorf("token endpoint: %s %s", result.Error, result.ErrorDescription)
+	}
+	if resp.StatusCode != http.StatusOK || result.IDToken == "" {
+		return "", fmt.Errorf("token endpoint вернул статус %d без id_token", resp.StatusCode)
+	}
+	return result.IDToken, nil
+}
+
+func (p *oidcProvider) verifyIDToken(ctx context.Context, rawToken, nonce string) (*IDTokenClaims, error) {
+	metadata, err := p.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	claims := &IDTokenClaims{}
+	token, err := jwt.ParseWithClaims(rawToken, claims,
+		func(token *jwt.Token) (interface{}, error) {
+			kid, _ := token.Header["kid"].(string)
+			return p.publicKey(ctx, kid)
+		},
+		jwt.WithValidMethods([]string{"RS256", "ES256"}),
+		jwt.WithIssuer(metadata.Issuer),
+		jwt.WithAudience(p.config.ClientID),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(time.Minute),
+	)
+	if err != nil || !token.Valid {
+		return nil, fmt.Errorf("%w: %v", ErrOIDCInvalidIDToken, err)
+	}
+	if claims.Subject == "" || subtle.ConstantTimeCompare([]byte(claims.Nonce), []byte(nonce)) != 1 {
+		return nil, ErrOIDCInvalidIDToken
+	}
+	return claims, nil
+}
+
+func fetchJSON(ctx context.Context, target string, dest interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := oidcClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s вернул статус %d", target, resp.StatusCode)
+	}
+	return json.NewDecoder(resp.Body).Decode(dest)
+}
+
+func fetchJWKS(ctx context.Context, target string) (map[string]interface{}, error) {
+	var set struct {
+		Keys []JWK `json:"keys"`
+	}
+	if err := fetchJSON(ctx, target, &set); err != nil {
+		return nil, err
+	}
+
+	keys := make(map[string]interface{})
+	for _, jwk := range set.Keys {
+		key, err := jwk.publicKey()
+		if err != nil {
+			log.Printf("Пропущен ключ %q из %s: %v", jwk.KeyID, target, err)
+			continue
+		}
+		if key != nil {
+			keys[jwk.KeyID] = key
+		}
+	}
+	return keys, nil
+}
+
+func (k JWK) publicKey() (interface{}, error) {
+	if k.Use != "" && k.Use != "sig" {
+		return nil, nil
+	}
+
+	switch k.KeyType {
+	case "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, nil
+
+	case "EC":
+		if k.Curve != "P-256" {
+			return nil, fmt.Errorf("неподдерживаемая кривая %q", k.Curve)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := base64.RawURLEncoding.DecodeString(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}, nil
+	}
+	return nil, nil
+}
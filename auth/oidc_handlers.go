@@ -0,0 +1,188 @@
+package auth
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/planer/backend/internal/audit"
+)
+
+const (
+	oidcStateCookie = "oidc_state"
+	oidcStateTTL    = 10 * time.Minute
+)
+
+type oidcStateClaims struct {
+	Provider string `json:"provider"`
+	State    string `json:"state"`
+	Nonce    string `json:"nonce"`
+	Verifier string `json:"verifier"`
+	Redirect string `json:"redirect"`
+	jwt.RegisteredClaims
+}
+
+func oidcStateAudience() string {
+	return tokenAudience() + ":oidc-state"
+}
+
+func safeRedirect(path string) string {
+	if !strings.HasPrefix(path, "/") || strings.HasPrefix(path, "//") || strings.HasPrefix(path, "/\\") {
+		return "/"
+	}
+	return path
+}
+
+func frontendURL(path string, fragment url.Values) string {
+	target := strings.TrimRight(getEnv("APP_BASE_URL", "http://localhost:3000"), "/") + path
+	if len(fragment) > 0 {
+		target += "#" + fragment.Encode()
+	}
+	return target
+}
+
+func redirectOIDCError(c *gin.Context, redirect, message string) {
+	c.Redirect(http.StatusFound, frontendURL(redirect, url.Values{"error": {message}}))
+}
+
+func OIDCProvidersHandler(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"providers": ListOIDCProviders()})
+}
+
+func OIDCLoginHandler(c *gin.Context) {
+	provider, err := lookupOIDCProvider(c.Param("provider"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	values := make([]string, 3)
+	for i := range values {
+		if values[i], err = newRefreshToken(); err != nil {
+			log.Printf("Ошибка при генерации параметров OIDC: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Не удалось начать вход"})
+			return
+		}
+	}
+	now := time.Now()
+	state := &oidcStateClaims{
+		Provider: provider.config.Name,
+		State:    values[0],
+		Nonce:    values[1],
+		Verifier: values[2],
+		Redirect: safeRedirect(c.DefaultQuery("redirect", "/")),
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    tokenIssuer(),
+			Audience:  jwt.ClaimStrings{oidcStateAudience()},
+			ExpiresAt: jwt.NewNumericDate(now.Add(oidcStateTTL)),
+			IssuedAt:  jwt.NewNumericDate(now),
+			ID:        uuid.New().String(),
+		},
+	}
+
+	authURL, err := provider.authCodeURL(c.Request.Context(), state.State, state.Nonce, state.Verifier)
+	if err != nil {
+		log.Printf("Ошибка при обращении к провайдеру %s: %v", provider.config.Name, err)
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Провайдер входа недоступен"})
+		return
+	}
+	signedState, err := activeKeys().sign(state)
+	if err != nil {
+		log.Printf("Ошибка при подписи состояния OIDC: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Не удалось начать вход"})
+		return
+	}
+
+	config := cookieConfig()
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(oidcStateCookie, signedState, int(oidcStateTTL.Seconds()), "/", config.domain, config.secure, true)
+	c.Redirect(http.StatusFound, authURL)
+}
+
+func OIDCCallbackHandler(c *gin.Context) {
+	name := c.Param("provider")
+	provider, err := lookupOIDCProvider(name)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	config := cookieConfig()
+	rawState, _ := c.Cookie(oidcStateCookie)
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(oidcStateCookie, "", -1, "/", config.domain, config.secure, true)
+
+	state := &oidcStateClaims{}
+	if rawState == "" || activeKeys().parse(rawState, state, oidcStateAudience()) != nil ||
+		state.Provider != name || state.State == "" || state.State != c.Query("state") {
+		redirectOIDCError(c, "/", ErrOIDCInvalidState.Error())
+		return
+	}
+	if idpError := c.Query("error"); idpError != "" {
+		redirectOIDCError(c, state.Redirect, "Вход отменён: "+idpError)
+		return
+	}
+
+	ctx := c.Request.Context()
+	idToken, err := provider.exchange(ctx, c.Query("code"), state.Verifier)
+	if err != nil {
+		log.Printf("Ошибка обмена кода у провайдера %s: %v", name, err)
+		redirectOIDCError(c, state.Redirect, "Не удалось выполнить вход через провайдера")
+		return
+	}
+	claims, err := provider.verifyIDToken(ctx, idToken, state.Nonce)
+	if err != nil {
+		log.Printf("Ошибка проверки ID-токена провайдера %s: %v", name, err)
+		redirectOIDCError(c, state.Redirect, ErrOIDCInvalidIDToken.Error())
+		return
+	}
+
+	user, created, err := linkIdentity(ctx, name, claims)
+	if errors.Is(err, ErrOIDCNoEmail) || errors.Is(err, ErrOIDCEmailUnverified) {
+		redirectOIDCError(c, state.Redirect, err.Error())
+		return
+	}
+	if err != nil {
+		log.Printf("Ошибка при привязке аккаунта %s: %v", name, err)
+		redirectOIDCError(c, state.Redirect, "Не удалось выполнить вход")
+		return
+	}
+
+	if user.BlockedAt != nil {
+		redirectOIDCError(c, state.Redirect, ErrUserBlocked.Error())
+		return
+	}
+
+	details := map[string]interface{}{"provider": name}
+	if created {
+		audit.Record(c, audit.Entry{ActorID: user.ID, Action: audit.ActionRegister, TargetType: audit.TargetUser, TargetID: user.ID, Details: details})
+	}
+	audit.Record(c, audit.Entry{ActorID: user.ID, Action: audit.ActionOIDCLogin, TargetType: audit.TargetUser, TargetID: user.ID, Details: details})
+
+	pair, err := IssueTokens(ctx, user, c.Request.UserAgent(), c.ClientIP())
+	if err == nil {
+		err = setAuthCookies(c, pair)
+	}
+	if err != nil {
+		log.Printf("Ошибка при создании сессии: %v", err)
+		redirectOIDCError(c, state.Redirect, "Не удалось выполнить вход")
+		return
+	}
+
+	if config.enabled {
+		c.Redirect(http.StatusFound, frontendURL(state.Redirect, nil))
+		return
+	}
+	c.Redirect(http.StatusFound, frontendURL(state.Redirect, url.Values{
+		"token":         {pair.AccessToken},
+		"refresh_token": {pair.RefreshToken},
+		"expires_in":    {strconv.Itoa(pair.ExpiresIn)},
+	}))
+}
@@ -0,0 +1,379 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/planer/backend/internal/database"
+)
+
+const (
+	mockClientID = "planer"
+	mockKeyID    = "mock-key"
+)
+
+type mockIdP struct {
+	server *httptest.Server
+	key    *rsa.PrivateKey
+
+	mu      sync.Mutex
+	form    url.Values
+	idToken string
+}
+
+func newMockIdP(t *testing.T) *mockIdP {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	idp := &mockIdP{key: key}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(oidcMetadata{
+			Issuer:                idp.server.URL,
+			AuthorizationEndpoint: idp.server.URL + "/authorize",
+			TokenEndpoint:         idp.server.URL + "/token",
+			JWKSURI:               idp.server.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(JWKS{Keys: []JWK{{
+			KeyType:   "RSA",
+			KeyID:     mockKeyID,
+			Algorithm: "RS256",
+			Use:       "sig",
+			N:         base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			E:         base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		idp.mu.Lock()
+		idp.form = r.PostForm
+		token := idp.idToken
+		idp.mu.Unlock()
+		json.NewEncoder(w).Encode(map[string]string{"id_token": token, "token_type": "Bearer"})
+	})
+
+	idp.server = httptest.NewServer(mux)
+	t.Cleanup(idp.server.Close)
+	return idp
+}
+
+func (idp *mockIdP) provider() *oidcProvider {
+	return &oidcProvider{config: OIDCProviderConfig{
+		Name:         "mock",
+		Issuer:       idp.server.URL,
+		ClientID:     mockClientID,
+		ClientSecret: "secret",
+		Scopes:       []string{"openid", "email"},
+		RedirectURL:  "http://localhost:8080/api/auth/oidc/mock/callback",
+	}}
+}
+
+func (idp *mockIdP) claims(nonce string) *IDTokenClaims {
+	now := time.Now()
+	return &IDTokenClaims{
+		Nonce:         nonce,
+		Email:         "user@example.com",
+		EmailVerified: true,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    idp.server.URL,
+			Subject:   "subject-1",
+			Audience:  jwt.ClaimStrings{mockClientID},
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(time.Hour)),
+		},
+	}
+}
+
+func (idp *mockIdP) sign(t *testing.T, claims *IDTokenClaims) string {
+	t.Helper()
+
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = mockKeyID
+	signed, err := token.SignedString(idp.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed
+}
+
+func TestOIDCAuthCodeURLSendsPKCEChallenge(t *testing.T) {
+	idp := newMockIdP(t)
+	provider := idp.provider()
+
+	rawURL, err := provider.authCodeURL(context.Background(), "state-1", "nonce-1", "verifier-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := parsed.Scheme + "://" + parsed.Host + parsed.Path; got != idp.server.URL+"/authorize" {
+		t.Fatalf("authorization endpoint = %q", got)
+	}
+
+	query := parsed.Query()
+	challenge := sha256.Sum256([]byte("verifier-1"))
+	want := map[string]string{
+		"client_id":             mockClientID,
+		"state":                 "state-1",
+		"nonce":                 "nonce-1",
+		"code_challenge":        base64.RawURLEncoding.EncodeToString(challenge[:]),
+		"code_challenge_method": "S256",
+		"response_type":         "code",
+	}
+	for key, value := range want {
+		if got := query.Get(key); got != value {
+			t.Errorf("%s = %q, want %q", key, got, value)
+		}
+	}
+	if query.Get("code_verifier") != "" {
+		t.Error("verifier must not leave the server in the authorization URL")
+	}
+}
+
+func TestOIDCExchangeSendsVerifier(t *testing.T) {
+	idp := newMockIdP(t)
+	provider := idp.provider()
+	idp.idToken = idp.sign(t, idp.claims("nonce-1"))
+
+	rawToken, err := provider.exchange(context.Background(), "code-1", "verifier-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rawToken != idp.idToken {
+		t.Fatalf("id_token = %q, want the one issued by the provider", rawToken)
+	}
+
+	idp.mu.Lock()
+	form := idp.form
+	idp.mu.Unlock()
+	for key, value := range map[string]string{
+		"grant_type":    "authorization_code",
+		"code":          "code-1",
+		"code_verifier": "verifier-1",
+		"client_id":     mockClientID,
+		"redirect_uri":  provider.config.RedirectURL,
+	} {
+		if got := form.Get(key); got != value {
+			t.Errorf("%s = %q, want %q", key, got, value)
+		}
+	}
+}
+
+func TestOIDCVerifyIDToken(t *testing.T) {
+	idp := newMockIdP(t)
+
+	tests := []struct {
+		name   string
+		mutate func(*IDTokenClaims)
+		nonce  string
+		valid  bool
+	}{
+		{name: "valid", nonce: "nonce-1", valid: true},
+		{name: "nonce mismatch", nonce: "nonce-2"},
+		{name: "empty nonce", nonce: ""},
+		{name: "wrong audience", nonce: "nonce-1", mutate: func(c *IDTokenClaims) {
+			c.Audience = jwt.ClaimStrings{"someone-else"}
+		}},
+		{name: "wrong issuer", nonce: "nonce-1", mutate: func(c *IDTokenClaims) {
+			c.Issuer = "https://evil.example.com"
+		}},
+		{name: "expired", nonce: "nonce-1", mutate: func(c *IDTokenClaims) {
+			c.ExpiresAt = jwt.NewNumericDate(time.Now().Add(-time.Hour))
+		}},
+		{name: "no subject", nonce: "nonce-1", mutate: func(c *IDTokenClaims) {
+			c.Subject = ""
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims := idp.claims("nonce-1")
+			if tt.mutate != nil {
+				tt.mutate(claims)
+			}
+
+			verified, err := idp.provider().verifyIDToken(context.Background(), idp.sign(t, claims), tt.nonce)
+			if tt.valid {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				if verified.Subject != "subject-1" || verified.Email != "user@example.com" || !bool(verified.EmailVerified) {
+					t.Fatalf("unexpected claims: %+v", verified)
+				}
+				return
+			}
+			if !errors.Is(err, ErrOIDCInvalidIDToken) {
+				t.Fatalf("err = %v, want ErrOIDCInvalidIDToken", err)
+			}
+		})
+	}
+}
+
+func TestOIDCVerifyIDTokenRejectsForeignKey(t *testing.T) {
+	idp := newMockIdP(t)
+	other := newMockIdP(t)
+
+	claims := idp.claims("nonce-1")
+	if _, err := idp.provider().verifyIDToken(context.Background(), other.sign(t, claims), "nonce-1"); !errors.Is(err, ErrOIDCInvalidIDToken) {
+		t.Fatalf("err = %v, want ErrOIDCInvalidIDToken", err)
+	}
+}
+
+func openTestDB(t *testing.T) {
+	t.Helper()
+
+	t.Setenv("MOCK_DB", "")
+	t.Setenv("DB_DRIVER", database.DriverSQLite)
+	t.Setenv("SQLITE_PATH", filepath.Join(t.TempDir(), "test.db"))
+	t.Setenv("DB_REQUIRED", "true")
+	database.MockMode = false
+	database.InitDB()
+	t.Cleanup(database.CloseDB)
+}
+
+func oidcClaims(subject, email string, verified bool) *IDTokenClaims {
+	return &IDTokenClaims{
+		Email:            email,
+		EmailVerified:    flexibleBool(verified),
+		Name:             "Мария",
+		RegisteredClaims: jwt.RegisteredClaims{Subject: subject},
+	}
+}
+
+func TestLinkIdentityCreatesUser(t *testing.T) {
+	openTestDB(t)
+	ctx := context.Background()
+
+	user, created, err := linkIdentity(ctx, "mock", oidcClaims("subject-1", "new@example.com", true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !created || !user.EmailVerified || user.Name != "Мария" {
+		t.Fatalf("created = %v, user = %+v", created, user)
+	}
+
+	again, created, err := linkIdentity(ctx, "mock", oidcClaims("subject-1", "renamed@example.com", true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if created || again.ID != user.ID {
+		t.Fatalf("repeat login created = %v, user %d, want existing user %d", created, again.ID, user.ID)
+	}
+}
+
+func TestLinkIdentityLinksVerifiedAccount(t *testing.T) {
+	openTestDB(t)
+	ctx := context.Background()
+
+	local, err := RegisterUser(ctx, "Мария", "user@example.com", "password123")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := database.DB.Exec("UPDATE users SET email_verified_at = CURRENT_TIMESTAMP WHERE id = $1", local.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	user, created, err := linkIdentity(ctx, "mock", oidcClaims("subject-1", "USER@example.com", true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if created || user.ID != local.ID {
+		t.Fatalf("created = %v, user %d, want %d", created, user.ID, local.ID)
+	}
+	if _, err := AuthenticateUser(ctx, "user@example.com", "password123"); err != nil {
+		t.Fatalf("verified owner must keep the password: %v", err)
+	}
+}
+
+func TestLinkIdentityClaimsUnverifiedAccount(t *testing.T) {
+	openTestDB(t)
+	ctx := context.Background()
+
+	squatter, err := RegisterUser(ctx, "Чужой", "victim@example.com", "attacker-password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := database.DB.Exec(
+		"INSERT INTO auth_sessions (id, user_id, expires_at) VALUES ($1, $2, $3)",
+		"session-1", squatter.ID, time.Now().Add(time.Hour),
+	); err != nil {
+		t.Fatal(err)
+	}
+
+	user, created, err := linkIdentity(ctx, "mock", oidcClaims("subject-1", "victim@example.com", true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if created || user.ID != squatter.ID || !user.EmailVerified {
+		t.Fatalf("created = %v, user = %+v", created, user)
+	}
+	if _, err := AuthenticateUser(ctx, "victim@example.com", "attacker-password"); err == nil {
+		t.Fatal("password set before the email was verified must stop working")
+	}
+
+	var revoked bool
+	if err := database.DB.QueryRow(
+		"SELECT revoked_at IS NOT NULL FROM auth_sessions WHERE id = $1", "session-1",
+	).Scan(&revoked); err != nil {
+		t.Fatal(err)
+	}
+	if !revoked {
+		t.Fatal("sessions opened before the email was verified must be revoked")
+	}
+}
+
+func TestLinkIdentityRejectsUnverifiedProviderEmail(t *testing.T) {
+	openTestDB(t)
+	ctx := context.Background()
+
+	if _, err := RegisterUser(ctx, "Мария", "user@example.com", "password123"); err != nil {
+		t.Fatal(err)
+	}
+
+	_, _, err := linkIdentity(ctx, "mock", oidcClaims("subject-1", "user@example.com", false))
+	if !errors.Is(err, ErrOIDCEmailUnverified) {
+		t.Fatalf("err = %v, want ErrOIDCEmailUnverified", err)
+	}
+
+	var count int
+	if err := database.DB.QueryRow("SELECT COUNT(*) FROM user_identities").Scan(&count); err != nil {
+		t.Fatal(err)
+	}
+	if count != 0 {
+		t.Fatalf("identities = %d, want none", count)
+	}
+}
+
+func TestLinkIdentityRequiresEmail(t *testing.T) {
+	openTestDB(t)
+
+	_, _, err := linkIdentity(context.Background(), "mock", oidcClaims("subject-1", " ", true))
+	if !errors.Is(err, ErrOIDCNoEmail) {
+		t.Fatalf("err = %v, want ErrOIDCNoEmail", err)
+	}
+}
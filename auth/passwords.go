@@ -0,0 +1,189 @@
+package auth
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/planer/backend/internal/database"
+	"github.com/planer/backend/internal/mailer"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var ErrResetTokenInvalid = errors.New("ссылка для сброса пароля недействительна или устарела")
+
+func passwordResetTTL() time.Duration {
+	return getEnvDuration("PASSWORD_RESET_TTL", time.Hour)
+}
+
+func RequestPasswordReset(ctx context.Context, email string) error {
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
+
+	user, err := scanUser(database.DB.QueryRowContext(ctx,
+		"SELECT "+userColumns+" FROM users WHERE email = $1",
+		email,
+	))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var recent int
+	err = database.DB.QueryRowContext(ctx,
+		"SELECT COUNT(*) FROM password_resets WHERE user_id = $1 AND used_at IS NULL AND created_at > $2",
+		user.ID, time.Now().UTC().Add(-verificationResendInterval()),
+	).Scan(&recent)
+	if err != nil {
+		return err
+	}
+	if recent > 0 {
+		return nil
+	}
+
+	token, err := newRefreshToken()
+	if err != nil {
+		return err
+	}
+
+	tx, err := database.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx,
+		"DELETE FROM password_resets WHERE user_id = $1 AND used_at IS NULL",
+		user.ID,
+	); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx,
+		"INSERT INTO password_resets (token_hash, user_id, expires_at, created_at) VALUES ($1, $2, $3, $4)",
+		hashRefreshToken(token), user.ID, time.Now().UTC().Add(passwordResetTTL()), time.Now().UTC(),
+	); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	return mailer.Send(ctx, mailer.Message{
+		To:      user.Email,
+		Subject: "Восстановление пароля",
+		Text: fmt.Sprintf("Здравствуйте, %s!\n\nЧтобы задать новый пароль, перейдите по ссылке:\n%s\n\nСсылка действительна %s и может быть использована один раз. Если вы не запрашивали сброс пароля, просто проигнорируйте это письмо.\n",
+			user.Name, appLink("/reset-password", token), passwordResetTTL()),
+	})
+}
+
+func ResetPassword(ctx context.Context, token, password string) (int, error) {
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return 0, err
+	}
+
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
+
+	tx, err := database.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	tokenHash := hashRefreshToken(token)
+	var userID int
+	var expiresAt time.Time
+	var usedAt sql.NullTime
+	err = tx.QueryRowContext(ctx,
+		"SELECT user_id, expires_at, used_at FROM password_resets WHERE token_hash = $1",
+		tokenHash,
+	).Scan(&userID, &expiresAt, &usedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, ErrResetTokenInvalid
+	}
+	if err != nil {
+		return 0, err
+	}
+	if usedAt.Valid || time.Now().After(expiresAt) {
+		return 0, ErrResetTokenInvalid
+	}
+
+	result, err := tx.ExecContext(ctx,
+		"UPDATE password_resets SET used_at = CURRENT_TIMESTAMP WHERE token_hash = $1 AND used_at IS NULL",
+		tokenHash,
+	)
+	if err != nil {
+		return 0, err
+	}
+	if affected, err := result.RowsAffected(); err != nil || affected == 0 {
+		if err == nil {
+			err = ErrResetTokenInvalid
+		}
+		return 0, err
+	}
+
+	if _, err := tx.ExecContext(ctx,
+		`UPDATE users SET password_hash = $1, email_verified_at = COALESCE(email_verified_at, CURRENT_TIMESTAMP),
+		updated_at = CURRENT_TIMESTAMP WHERE id = $2`,
+		string(hashedPassword), userID,
+	); err != nil {
+		return 0, err
+	}
+	if err := revokeSessionsTx(ctx, tx, userID); err != nil {
+		return 0, err
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return userID, nil
+}
+
+func ChangePassword(ctx context.Context, userID int, currentPassword, newPassword string) error {
+	if err := CheckPassword(ctx, userID, currentPassword); err != nil {
+		return err
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(newPassword), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
+
+	tx, err := database.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx,
+		"UPDATE users SET password_hash = $1, updated_at = CURRENT_TIMESTAMP WHERE id = $2",
+		string(hashedPassword), userID,
+	); err != nil {
+		return err
+	}
+	if err := revokeSessionsTx(ctx, tx, userID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func revokeSessionsTx(ctx context.Context, tx *sql.Tx, userID int) error {
+	if _, err := tx.ExecContext(ctx,
+		"UPDATE auth_sessions SET revoked_at = CURRENT_TIMESTAMP WHERE user_id = $1 AND revoked_at IS NULL",
+		userID,
+	); err != nil {
+		return err
+	}
+	_, err := tx.ExecContext(ctx,
+		"DELETE FROM password_resets WHERE user_id = $1 AND used_at IS NULL",
+		userID,
+	)
+	return err
+}
@@ -0,0 +1,129 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/planer/backend/internal/database"
+	"github.com/planer/backend/internal/mailer"
+)
+
+type capturingMailer struct {
+	messages []mailer.Message
+}
+
+func (m *capturingMailer) Send(ctx context.Context, msg mailer.Message) error {
+	m.messages = append(m.messages, msg)
+	return nil
+}
+
+func captureMail(t *testing.T) *capturingMailer {
+	t.Helper()
+
+	captured := &capturingMailer{}
+	previous := mailer.Default
+	mailer.Default = captured
+	t.Cleanup(func() { mailer.Default = previous })
+	return captured
+}
+
+func (m *capturingMailer) lastToken(t *testing.T) string {
+	t.Helper()
+
+	if len(m.messages) == 0 {
+		t.Fatal("no mail was sent")
+	}
+	_, rest, found := strings.Cut(m.messages[len(m.messages)-1].Text, "?token=")
+	if !found {
+		t.Fatal("mail contains no token link")
+	}
+	escaped, _, _ := strings.Cut(rest, "\n")
+	token, err := url.QueryUnescape(escaped)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+func TestPasswordResetTokenIsSingleUse(t *testing.T) {
+	openTestDB(t)
+	captured := captureMail(t)
+	ctx := context.Background()
+
+	user, err := RegisterUser(ctx, "Мария", "user@example.com", "password123")
+	if err != nil {
+		t.Fatal(err)
+	}
+	session, err := IssueTokens(ctx, user, "test", "127.0.0.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := RequestPasswordReset(ctx, "user@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	token := captured.lastToken(t)
+
+	userID, err := ResetPassword(ctx, token, "new-password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if userID != user.ID {
+		t.Fatalf("reset returned user %d, want %d", userID, user.ID)
+	}
+	if err := CheckPassword(ctx, user.ID, "new-password"); err != nil {
+		t.Fatalf("new password rejected: %v", err)
+	}
+	if _, _, err := RefreshTokens(ctx, session.RefreshToken); !errors.Is(err, ErrRefreshTokenInvalid) {
+		t.Fatalf("session survived the password reset: err = %v", err)
+	}
+
+	if _, err := ResetPassword(ctx, token, "another-password"); !errors.Is(err, ErrResetTokenInvalid) {
+		t.Fatalf("second use of the token: err = %v, want ErrResetTokenInvalid", err)
+	}
+	if err := CheckPassword(ctx, user.ID, "new-password"); err != nil {
+		t.Fatalf("replayed token changed the password: %v", err)
+	}
+}
+
+func TestPasswordResetRejectsExpiredToken(t *testing.T) {
+	openTestDB(t)
+	captured := captureMail(t)
+	ctx := context.Background()
+
+	user, err := RegisterUser(ctx, "Мария", "user@example.com", "password123")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := RequestPasswordReset(ctx, "user@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	token := captured.lastToken(t)
+
+	if _, err := database.DB.Exec("UPDATE password_resets SET expires_at = $1 WHERE user_id = $2",
+		time.Now().UTC().Add(-time.Minute), user.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ResetPassword(ctx, token, "new-password"); !errors.Is(err, ErrResetTokenInvalid) {
+		t.Fatalf("expired token: err = %v, want ErrResetTokenInvalid", err)
+	}
+	if err := CheckPassword(ctx, user.ID, "password123"); err != nil {
+		t.Fatalf("expired token changed the password: %v", err)
+	}
+}
+
+func TestPasswordResetUnknownEmail(t *testing.T) {
+	openTestDB(t)
+	captured := captureMail(t)
+
+	if err := RequestPasswordReset(context.Background(), "nobody@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	if len(captured.messages) != 0 {
+		t.Fatalf("mail sent for an unknown address: %+v", captured.messages)
+	}
+}
@@ -0,0 +1,259 @@
+package auth
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/planer/backend/internal/database"
+	"github.com/planer/backend/internal/mailer"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var (
+	Languages   = []string{"ru", "en"}
+	UnitSystems = []string{"metric", "imperial"}
+)
+
+var (
+	ErrInvalidLanguage = errors.New("неподдерживаемый язык")
+	ErrInvalidUnits    = errors.New("неподдерживаемая система единиц")
+	ErrEmptyName       = errors.New("имя не может быть пустым")
+	ErrSameEmail       = errors.New("новый email совпадает с текущим")
+	ErrReauthRequired  = errors.New("для смены email войдите в аккаунт заново")
+)
+
+type ProfileUpdate struct {
+	Name     *string
+	Language *string
+	Units    *string
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func UpdateProfile(ctx context.Context, userID int, update ProfileUpdate) (*User, error) {
+	var sets []string
+	var args []interface{}
+	set := func(column string, value interface{}) {
+		args = append(args, value)
+		sets = append(sets, fmt.Sprintf("%s = $%d", column, len(args)))
+	}
+
+	if update.Name != nil {
+		name := strings.TrimSpace(*update.Name)
+		if name == "" {
+			return nil, ErrEmptyName
+		}
+		set("name", name)
+	}
+	if update.Language != nil {
+		if !contains(Languages, *update.Language) {
+			return nil, ErrInvalidLanguage
+		}
+		set("language", *update.Language)
+	}
+	if update.Units != nil {
+		if !contains(UnitSystems, *update.Units) {
+			return nil, ErrInvalidUnits
+		}
+		set("units", *update.Units)
+	}
+	if len(sets) == 0 {
+		return GetUserByID(ctx, userID)
+	}
+
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
+
+	args = append(args, userID)
+	return scanUser(database.DB.QueryRowContext(ctx,
+		fmt.Sprintf("UPDATE users SET %s, updated_at = CURRENT_TIMESTAMP WHERE id = $%d RETURNING %s",
+			strings.Join(sets, ", "), len(args), userColumns),
+		args...,
+	))
+}
+
+func emailChangeReauthWindow() time.Duration {
+	return getEnvDuration("EMAIL_CHANGE_REAUTH_WINDOW", 10*time.Minute)
+}
+
+func ConfirmIdentity(ctx context.Context, userID int, sessionID, password string) error {
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
+
+	var passwordHash string
+	if err := database.DB.QueryRowContext(ctx,
+		"SELECT password_hash FROM users WHERE id = $1",
+		userID,
+	).Scan(&passwordHash); err != nil {
+		return err
+	}
+	if passwordHash != "" {
+		if bcrypt.CompareHashAndPassword([]byte(passwordHash), []byte(password)) != nil {
+			return ErrInvalidPassword
+		}
+		return nil
+	}
+
+	if sessionID == "" {
+		return ErrReauthRequired
+	}
+	var createdAt time.Time
+	err := database.DB.QueryRowContext(ctx,
+		"SELECT created_at FROM auth_sessions WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL",
+		sessionID, userID,
+	).Scan(&createdAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrReauthRequired
+	}
+	if err != nil {
+		return err
+	}
+	if time.Since(createdAt) > emailChangeReauthWindow() {
+		return ErrReauthRequired
+	}
+	return nil
+}
+
+func ChangeEmail(ctx context.Context, userID int, sessionID, password, email string) (*User, error) {
+	if err := ConfirmIdentity(ctx, userID, sessionID, password); err != nil {
+		return nil, err
+	}
+
+	current, err := GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	email = strings.TrimSpace(email)
+	if strings.EqualFold(current.Email, email) {
+		return nil, ErrSameEmail
+	}
+
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
+
+	var taken bool
+	if err := database.DB.QueryRowContext(ctx,
+		"SELECT EXISTS (SELECT 1 FROM users WHERE LOWER(email) = LOWER($1))",
+		email,
+	).Scan(&taken); err != nil {
+		return nil, err
+	}
+	if taken {
+		return nil, ErrEmailExists
+	}
+
+	return scanUser(database.DB.QueryRowContext(ctx,
+		`UPDATE users SET pending_email = $1, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $2
+		RETURNING `+userColumns,
+		email, userID,
+	))
+}
+
+func CancelEmailChange(ctx context.Context, userID int) (*User, error) {
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
+
+	return scanUser(database.DB.QueryRowContext(ctx,
+		`UPDATE users SET pending_email = NULL,
+			updated_at = CASE WHEN pending_email IS NULL THEN updated_at ELSE CURRENT_TIMESTAMP END
+		WHERE id = $1
+		RETURNING `+userColumns,
+		userID,
+	))
+}
+
+func SendEmailChangeConfirmation(ctx context.Context, user *User) error {
+	if user.PendingEmail == "" {
+		return nil
+	}
+
+	token, err := generateVerificationToken(&User{ID: user.ID, Email: user.PendingEmail}, emailChangeSubject)
+	if err != nil {
+		return err
+	}
+
+	return mailer.Send(ctx, mailer.Message{
+		To:      user.PendingEmail,
+		Subject: "Подтверждение нового email",
+		Text: fmt.Sprintf("Здравствуйте, %s!\n\nЧтобы сделать этот адрес адресом входа в аккаунт, перейдите по ссылке:\n%s\n\nСсылка действительна %s. Если вы не запрашивали смену адреса, просто проигнорируйте это письмо.\n",
+			user.Name, appLink("/verify-email", token), verificationTTL()),
+	})
+}
+
+func NotifyEmailChangeRequested(ctx context.Context, user *User) error {
+	return mailer.Send(ctx, mailer.Message{
+		To:      user.Email,
+		Subject: "Запрошена смена адреса электронной почты",
+		Text: fmt.Sprintf("Здравствуйте, %s!\n\nДля вашего аккаунта запрошена смена адреса электронной почты на %s. Адрес изменится только после подтверждения по ссылке из письма на новый адрес, до этого вход и восстановление пароля работают через текущий адрес.\n\nЕсли это сделали не вы, смените пароль и отмените смену адреса в настройках профиля.\n",
+			user.Name, user.PendingEmail),
+	})
+}
+
+func confirmEmailChange(ctx context.Context, userID int, email string) (*User, string, error) {
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
+
+	tx, err := database.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	defer tx.Rollback()
+
+	var oldEmail string
+	err = tx.QueryRowContext(ctx,
+		"SELECT email FROM users WHERE id = $1 AND pending_email = $2",
+		userID, email,
+	).Scan(&oldEmail)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, "", ErrVerificationInvalid
+	}
+	if err != nil {
+		return nil, "", err
+	}
+
+	user, err := scanUser(tx.QueryRowContext(ctx,
+		`UPDATE users SET email = pending_email, pending_email = NULL,
+			email_verified_at = CURRENT_TIMESTAMP, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1
+		RETURNING `+userColumns,
+		userID,
+	))
+	if database.IsUniqueViolation(err) {
+		return nil, "", ErrEmailExists
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	if _, err := tx.ExecContext(ctx,
+		"DELETE FROM password_resets WHERE user_id = $1 AND used_at IS NULL",
+		userID,
+	); err != nil {
+		return nil, "", err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, "", err
+	}
+	return user, oldEmail, nil
+}
+
+func NotifyEmailChanged(ctx context.Context, user *User, oldEmail string) error {
+	return mailer.Send(ctx, mailer.Message{
+		To:      oldEmail,
+		Subject: "Адрес электронной почты изменён",
+		Text: fmt.Sprintf("Здравствуйте, %s!\n\nАдрес электронной почты вашего аккаунта изменён на %s, новый адрес подтверждён. Если это сделали не вы, обратитесь в поддержку.\n",
+			user.Name, user.Email),
+	})
+}
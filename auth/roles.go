@@ -0,0 +1,157 @@
+package auth
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/planer/backend/internal/database"
+)
+
+const (
+	RoleClient   = "client"
+	RoleDesigner = "designer"
+	RoleAdmin    = "admin"
+)
+
+var Roles = []string{RoleClient, RoleDesigner, RoleAdmin}
+
+type Permission string
+
+const (
+	PermManageOwnProjects    Permission = "projects:own"
+	PermManageClientProjects Permission = "projects:clients"
+)
+
+var rolePermissions = map[string][]Permission{
+	RoleClient:   {PermManageOwnProjects},
+	RoleDesigner: {PermManageOwnProjects, PermManageClientProjects},
+	RoleAdmin:    {PermManageOwnProjects, PermManageClientProjects},
+}
+
+var ErrUnknownRole = errors.New("неизвестная роль")
+
+func ValidRole(role string) bool {
+	_, ok := rolePermissions[role]
+	return ok
+}
+
+func HasPermission(role string, permission Permission) bool {
+	for _, p := range rolePermissions[role] {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
+
+func IsAdmin(user *User) bool {
+	return user.Role == RoleAdmin
+}
+
+func SetUserRole(ctx context.Context, userID int, role string) error {
+	if !ValidRole(role) {
+		return ErrUnknownRole
+	}
+
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
+
+	tx, err := database.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx,
+		"UPDATE users SET role = $1, updated_at = CURRENT_TIMESTAMP WHERE id = $2",
+		role, userID,
+	)
+	if err != nil {
+		return err
+	}
+	if affected, err := result.RowsAffected(); err != nil || affected == 0 {
+		if err == nil {
+			err = sql.ErrNoRows
+		}
+		return err
+	}
+	if !HasPermission(role, PermManageClientProjects) {
+		if _, err := tx.ExecContext(ctx, "DELETE FROM project_designers WHERE user_id = $1", userID); err != nil {
+			return err
+		}
+	}
+	if err := revokeSessionsTx(ctx, tx, userID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func SetUserRoleByEmail(ctx context.Context, email, role string) error {
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
+
+	var userID int
+	err := database.DB.QueryRowContext(ctx,
+		"SELECT id FROM users WHERE LOWER(email) = LOWER($1)",
+		strings.TrimSpace(email),
+	).Scan(&userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("пользователь %s не найден", email)
+	}
+	if err != nil {
+		return err
+	}
+	return SetUserRole(ctx, userID, role)
+}
+
+func SetUserBlocked(ctx context.Context, userID int, blocked bool) error {
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
+
+	tx, err := database.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := "UPDATE users SET blocked_at = NULL, updated_at = CURRENT_TIMESTAMP WHERE id = $1"
+	if blocked {
+		query = "UPDATE users SET blocked_at = COALESCE(blocked_at, CURRENT_TIMESTAMP), updated_at = CURRENT_TIMESTAMP WHERE id = $1"
+	}
+	result, err := tx.ExecContext(ctx, query, userID)
+	if err != nil {
+		return err
+	}
+	if affected, err := result.RowsAffected(); err != nil || affected == 0 {
+		if err == nil {
+			err = sql.ErrNoRows
+		}
+		return err
+	}
+	if blocked {
+		if err := revokeSessionsTx(ctx, tx, userID); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func RequireRole(roles ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		role := c.GetString("role")
+		for _, r := range roles {
+			if r == role {
+				c.Next()
+				return
+			}
+		}
+
+		c.Header("WWW-Authenticate", challenge("insufficient_scope", "role "+strings.Join(roles, " or ")+" required"))
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Недостаточно прав"})
+	}
+}
@@ -0,0 +1,281 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/planer/backend/internal/database"
+)
+
+var (
+	ErrRefreshTokenInvalid = errors.New("недействительный refresh-токен")
+	ErrRefreshTokenReused  = errors.New("refresh-токен уже использован, сессия отозвана")
+)
+
+type TokenPair struct {
+	AccessToken  string
+	RefreshToken string
+	ExpiresIn    int
+}
+
+func accessTTL() time.Duration {
+	return getEnvDuration("JWT_ACCESS_TTL", 15*time.Minute)
+}
+
+func refreshTTL() time.Duration {
+	return getEnvDuration("JWT_REFRESH_TTL", 30*24*time.Hour)
+}
+
+func hashRefreshToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func newRefreshToken() (string, error) {
+	data := make([]byte, 32)
+	if _, err := rand.Read(data); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+func insertRefreshToken(ctx context.Context, tx *sql.Tx, sessionID string, expiresAt time.Time) (string, error) {
+	token, err := newRefreshToken()
+	if err != nil {
+		return "", err
+	}
+	_, err = tx.ExecContext(ctx,
+		"INSERT INTO refresh_tokens (token_hash, session_id, expires_at) VALUES ($1, $2, $3)",
+		hashRefreshToken(token), sessionID, expiresAt,
+	)
+	return token, err
+}
+
+func issuePair(user *User, sessionID, refreshToken string) (*TokenPair, error) {
+	accessToken, err := GenerateToken(user, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	return &TokenPair{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		ExpiresIn:    int(accessTTL().Seconds()),
+	}, nil
+}
+
+func IssueTokens(ctx context.Context, user *User, userAgent, ip string) (*TokenPair, error) {
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
+
+	tx, err := database.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	sessionID := uuid.New().String()
+	expiresAt := time.Now().UTC().Add(refreshTTL())
+	_, err = tx.ExecContext(ctx,
+		"INSERT INTO auth_sessions (id, user_id, user_agent, ip, expires_at) VALUES ($1, $2, $3, $4, $5)",
+		sessionID, user.ID, userAgent, ip, expiresAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	refreshToken, err := insertRefreshToken(ctx, tx, sessionID, expiresAt)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return issuePair(user, sessionID, refreshToken)
+}
+
+func RefreshTokens(ctx context.Context, refreshToken string) (*TokenPair, int, error) {
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
+
+	tx, err := database.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer tx.Rollback()
+
+	tokenHash := hashRefreshToken(refreshToken)
+	var sessionID string
+	var userID int
+	var expiresAt time.Time
+	var usedAt, revokedAt sql.NullTime
+	err = tx.QueryRowContext(ctx,
+		`SELECT s.id, s.user_id, t.expires_at, t.used_at, s.revoked_at
+		FROM refresh_tokens t
+		JOIN auth_sessions s ON s.id = t.session_id
+		WHERE t.token_hash = $1`,
+		tokenHash,
+	).Scan(&sessionID, &userID, &expiresAt, &usedAt, &revokedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, 0, ErrRefreshTokenInvalid
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+	if revokedAt.Valid || time.Now().After(expiresAt) {
+		return nil, userID, ErrRefreshTokenInvalid
+	}
+
+	result, err := tx.ExecContext(ctx,
+		"UPDATE refresh_tokens SET used_at = CURRENT_TIMESTAMP WHERE token_hash = $1 AND used_at IS NULL",
+		tokenHash,
+	)
+	if err != nil {
+		return nil, 0, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return nil, 0, err
+	}
+	if usedAt.Valid || affected == 0 {
+		if _, err := tx.ExecContext(ctx,
+			"UPDATE auth_sessions SET revoked_at = CURRENT_TIMESTAMP WHERE id = $1 AND revoked_at IS NULL",
+			sessionID,
+		); err != nil {
+			return nil, 0, err
+		}
+		if err := tx.Commit(); err != nil {
+			return nil, 0, err
+		}
+		return nil, userID, ErrRefreshTokenReused
+	}
+
+	newExpiresAt := time.Now().UTC().Add(refreshTTL())
+	newToken, err := insertRefreshToken(ctx, tx, sessionID, newExpiresAt)
+	if err != nil {
+		return nil, 0, err
+	}
+	if _, err := tx.ExecContext(ctx,
+		"UPDATE auth_sessions SET last_used_at = CURRENT_TIMESTAMP, expires_at = $1 WHERE id = $2",
+		newExpiresAt, sessionID,
+	); err != nil {
+		return nil, 0, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, 0, err
+	}
+
+	user, err := GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, 0, err
+	}
+	if user.BlockedAt != nil {
+		return nil, userID, ErrRefreshTokenInvalid
+	}
+	pair, err := issuePair(user, sessionID, newToken)
+	return pair, userID, err
+}
+
+func RevokeSession(ctx context.Context, userID int, sessionID string) (bool, error) {
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
+
+	result, err := database.DB.ExecContext(ctx,
+		"UPDATE auth_sessions SET revoked_at = CURRENT_TIMESTAMP WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL",
+		sessionID, userID,
+	)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
+
+func RevokeUserSessions(ctx context.Context, userID int) error {
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
+
+	_, err := database.DB.ExecContext(ctx,
+		"UPDATE auth_sessions SET revoked_at = CURRENT_TIMESTAMP WHERE user_id = $1 AND revoked_at IS NULL",
+		userID,
+	)
+	return err
+}
+
+func sessionActive(ctx context.Context, userID int, sessionID string) (bool, error) {
+	if sessionID == "" {
+		return false, nil
+	}
+
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
+
+	var revokedAt sql.NullTime
+	err := database.DB.QueryRowContext(ctx,
+		"SELECT revoked_at FROM auth_sessions WHERE id = $1 AND user_id = $2",
+		sessionID, userID,
+	).Scan(&revokedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return !revokedAt.Valid, nil
+}
+
+func StartSessionPurger(interval time.Duration) {
+	if database.MockMode {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			if err := purgeSessions(context.Background()); err != nil {
+				log.Printf("Ошибка при очистке сессий: %v", err)
+			}
+			<-ticker.C
+		}
+	}()
+}
+
+func purgeSessions(ctx context.Context) error {
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
+
+	now := time.Now().UTC()
+	result, err := database.DB.ExecContext(ctx,
+		"DELETE FROM auth_sessions WHERE expires_at < $1 OR revoked_at < $2",
+		now, now.Add(-accessTTL()),
+	)
+	if err != nil {
+		return err
+	}
+	if purged, _ := result.RowsAffected(); purged > 0 {
+		log.Printf("Удалено устаревших сессий: %d", purged)
+	}
+
+	if _, err := database.DB.ExecContext(ctx,
+		"DELETE FROM refresh_tokens WHERE expires_at < $1",
+		now,
+	); err != nil {
+		return err
+	}
+
+	_, err = database.DB.ExecContext(ctx,
+		"DELETE FROM password_resets WHERE expires_at < $1",
+		now,
+	)
+	return err
+}
@@ -0,0 +1,94 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func requestWithBearer(router *gin.Engine, token string) *httptest.ResponseRecorder {
+	recorder := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/profile", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	router.ServeHTTP(recorder, req)
+	return recorder
+}
+
+func TestRefreshTokenRotation(t *testing.T) {
+	openTestDB(t)
+	ctx := context.Background()
+
+	user, err := RegisterUser(ctx, "Мария", "user@example.com", "password123")
+	if err != nil {
+		t.Fatal(err)
+	}
+	first, err := IssueTokens(ctx, user, "test", "127.0.0.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	second, userID, err := RefreshTokens(ctx, first.RefreshToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if userID != user.ID {
+		t.Fatalf("refresh returned user %d, want %d", userID, user.ID)
+	}
+	if second.RefreshToken == first.RefreshToken {
+		t.Fatal("refresh token was not rotated")
+	}
+
+	router := newScopedRouter()
+	if recorder := requestWithBearer(router, second.AccessToken); recorder.Code != http.StatusOK {
+		t.Fatalf("rotated access token: status %d, want 200", recorder.Code)
+	}
+
+	if _, _, err := RefreshTokens(ctx, "unknown"); !errors.Is(err, ErrRefreshTokenInvalid) {
+		t.Fatalf("unknown refresh token: err = %v, want ErrRefreshTokenInvalid", err)
+	}
+}
+
+func TestRefreshTokenReuseRevokesSession(t *testing.T) {
+	openTestDB(t)
+	ctx := context.Background()
+
+	user, err := RegisterUser(ctx, "Мария", "user@example.com", "password123")
+	if err != nil {
+		t.Fatal(err)
+	}
+	first, err := IssueTokens(ctx, user, "test", "127.0.0.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, _, err := RefreshTokens(ctx, first.RefreshToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, userID, err := RefreshTokens(ctx, first.RefreshToken)
+	if !errors.Is(err, ErrRefreshTokenReused) {
+		t.Fatalf("replayed refresh token: err = %v, want ErrRefreshTokenReused", err)
+	}
+	if userID != user.ID {
+		t.Fatalf("reuse reported user %d, want %d", userID, user.ID)
+	}
+
+	if _, _, err := RefreshTokens(ctx, second.RefreshToken); !errors.Is(err, ErrRefreshTokenInvalid) {
+		t.Fatalf("refresh after reuse: err = %v, want ErrRefreshTokenInvalid", err)
+	}
+	if recorder := requestWithBearer(newScopedRouter(), second.AccessToken); recorder.Code != http.StatusUnauthorized {
+		t.Fatalf("access token of a revoked session: status %d, want 401", recorder.Code)
+	}
+
+	other, err := IssueTokens(ctx, user, "test", "127.0.0.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := RefreshTokens(ctx, other.RefreshToken); err != nil {
+		t.Fatalf("reuse in one session must not affect another: %v", err)
+	}
+}
@@ -0,0 +1,241 @@
+package auth
+
+import (
+	"context"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+type ThrottleEntry struct {
+	Failures    int
+	Lockouts    int
+	LockedUntil time.Time
+	UpdatedAt   time.Time
+}
+
+type ThrottleStore interface {
+	Get(ctx context.Context, key string) (ThrottleEntry, error)
+	Update(ctx context.Context, key string, fn func(entry *ThrottleEntry)) (ThrottleEntry, error)
+	Delete(ctx context.Context, key string) error
+}
+
+type MemoryThrottleStore struct {
+	mu        sync.Mutex
+	entries   map[string]ThrottleEntry
+	ttl       time.Duration
+	lastSweep time.Time
+}
+
+func NewMemoryThrottleStore(ttl time.Duration) *MemoryThrottleStore {
+	return &MemoryThrottleStore{
+		entries: make(map[string]ThrottleEntry),
+		ttl:     ttl,
+	}
+}
+
+func (s *MemoryThrottleStore) Get(ctx context.Context, key string) (ThrottleEntry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry, ok := s.entries[key]
+	if ok && s.expired(entry, time.Now()) {
+		delete(s.entries, key)
+		return ThrottleEntry{}, nil
+	}
+	return entry, nil
+}
+
+func (s *MemoryThrottleStore) Update(ctx context.Context, key string, fn func(entry *ThrottleEntry)) (ThrottleEntry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	s.sweep(now)
+
+	entry := s.entries[key]
+	if s.expired(entry, now) {
+		entry = ThrottleEntry{}
+	}
+	fn(&entry)
+	entry.UpdatedAt = now
+	s.entries[key] = entry
+	return entry, nil
+}
+
+func (s *MemoryThrottleStore) Delete(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.entries, key)
+	return nil
+}
+
+func (s *MemoryThrottleStore) expired(entry ThrottleEntry, now time.Time) bool {
+	return !entry.UpdatedAt.IsZero() && now.After(entry.UpdatedAt.Add(s.ttl)) && now.After(entry.LockedUntil)
+}
+
+func (s *MemoryThrottleStore) sweep(now time.Time) {
+	if now.Sub(s.lastSweep) < time.Minute {
+		return
+	}
+	s.lastSweep = now
+	for key, entry := range s.entries {
+		if s.expired(entry, now) {
+			delete(s.entries, key)
+		}
+	}
+}
+
+type ThrottlePolicy struct {
+	MaxAttempts int
+	Window      time.Duration
+	BaseLockout time.Duration
+	MaxLockout  time.Duration
+}
+
+func (p ThrottlePolicy) lockout(lockouts int) time.Duration {
+	duration := p.BaseLockout
+	for i := 1; i < lockouts && duration < p.MaxLockout; i++ {
+		duration *= 2
+	}
+	if duration > p.MaxLockout {
+		duration = p.MaxLockout
+	}
+	return duration
+}
+
+type LoginThrottle struct {
+	store   ThrottleStore
+	account ThrottlePolicy
+	ip      ThrottlePolicy
+}
+
+type Lockout struct {
+	Scope    string
+	Duration time.Duration
+}
+
+func loginThrottleConfig() (ThrottlePolicy, ThrottlePolicy) {
+	window := getEnvDuration("LOGIN_ATTEMPT_WINDOW", 15*time.Minute)
+	base := getEnvDuration("LOGIN_LOCKOUT_BASE", time.Minute)
+	maxLockout := getEnvDuration("LOGIN_LOCKOUT_MAX", time.Hour)
+	account := ThrottlePolicy{
+		MaxAttempts: getEnvInt("LOGIN_MAX_ATTEMPTS_ACCOUNT", 5),
+		Window:      window,
+		BaseLockout: base,
+		MaxLockout:  maxLockout,
+	}
+	ip := ThrottlePolicy{
+		MaxAttempts: getEnvInt("LOGIN_MAX_ATTEMPTS_IP", 20),
+		Window:      window,
+		BaseLockout: base,
+		MaxLockout:  maxLockout,
+	}
+	return account, ip
+}
+
+func NewLoginThrottle(store ThrottleStore) *LoginThrottle {
+	account, ip := loginThrottleConfig()
+	return &LoginThrottle{store: store, account: account, ip: ip}
+}
+
+var (
+	throttleMu    sync.RWMutex
+	loginThrottle *LoginThrottle
+)
+
+func SetThrottleStore(store ThrottleStore) {
+	throttleMu.Lock()
+	defer throttleMu.Unlock()
+	loginThrottle = NewLoginThrottle(store)
+}
+
+func activeThrottle() *LoginThrottle {
+	throttleMu.RLock()
+	throttle := loginThrottle
+	throttleMu.RUnlock()
+	if throttle != nil {
+		return throttle
+	}
+
+	throttleMu.Lock()
+	defer throttleMu.Unlock()
+	if loginThrottle == nil {
+		account, _ := loginThrottleConfig()
+		loginThrottle = NewLoginThrottle(NewMemoryThrottleStore(account.Window + account.MaxLockout))
+	}
+	return loginThrottle
+}
+
+func accountKey(email string) string {
+	return "login:account:" + strings.ToLower(strings.TrimSpace(email))
+}
+
+func ipKey(ip string) string {
+	return "login:ip:" + ip
+}
+
+func (t *LoginThrottle) Check(ctx context.Context, email, ip string) (time.Duration, error) {
+	var retryAfter time.Duration
+	for _, key := range []string{accountKey(email), ipKey(ip)} {
+		entry, err := t.store.Get(ctx, key)
+		if err != nil {
+			return 0, err
+		}
+		if wait := time.Until(entry.LockedUntil); wait > retryAfter {
+			retryAfter = wait
+		}
+	}
+	return retryAfter, nil
+}
+
+func (t *LoginThrottle) RecordFailure(ctx context.Context, email, ip string) ([]Lockout, error) {
+	var lockouts []Lockout
+	scopes := []struct {
+		name   string
+		key    string
+		policy ThrottlePolicy
+	}{
+		{"account", accountKey(email), t.account},
+		{"ip", ipKey(ip), t.ip},
+	}
+
+	for _, scope := range scopes {
+		var locked time.Duration
+		_, err := t.store.Update(ctx, scope.key, func(entry *ThrottleEntry) {
+			now := time.Now()
+			if !entry.UpdatedAt.IsZero() && now.Sub(entry.UpdatedAt) > scope.policy.Window {
+				entry.Failures = 0
+			}
+			entry.Failures++
+			if entry.Failures >= scope.policy.MaxAttempts {
+				entry.Lockouts++
+				entry.Failures = 0
+				locked = scope.policy.lockout(entry.Lockouts)
+				entry.LockedUntil = now.Add(locked)
+			}
+		})
+		if err != nil {
+			return lockouts, err
+		}
+		if locked > 0 {
+			lockouts = append(lockouts, Lockout{Scope: scope.name, Duration: locked})
+		}
+	}
+	return lockouts, nil
+}
+
+func (t *LoginThrottle) RecordSuccess(ctx context.Context, email string) error {
+	return t.store.Delete(ctx, accountKey(email))
+}
+
+func getEnvInt(key string, defaultValue int) int {
+	value, err := strconv.Atoi(os.Getenv(key))
+	if err != nil || value <= 0 {
+		return defaultValue
+	}
+	return value
+}
@@ -0,0 +1,99 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestThrottlePolicyLockoutEscalates(t *testing.T) {
+	policy := ThrottlePolicy{BaseLockout: time.Minute, MaxLockout: 10 * time.Minute}
+
+	want := []time.Duration{time.Minute, 2 * time.Minute, 4 * time.Minute, 8 * time.Minute, 10 * time.Minute, 10 * time.Minute}
+	for i, expected := range want {
+		if got := policy.lockout(i + 1); got != expected {
+			t.Errorf("lockout(%d) = %v, want %v", i+1, got, expected)
+		}
+	}
+}
+
+func failLogins(t *testing.T, throttle *LoginThrottle, email, ip string, count int) []Lockout {
+	t.Helper()
+
+	var lockouts []Lockout
+	for i := 0; i < count; i++ {
+		result, err := throttle.RecordFailure(context.Background(), email, ip)
+		if err != nil {
+			t.Fatal(err)
+		}
+		lockouts = append(lockouts, result...)
+	}
+	return lockouts
+}
+
+func TestLoginThrottleAccountLockout(t *testing.T) {
+	t.Setenv("LOGIN_MAX_ATTEMPTS_ACCOUNT", "3")
+	t.Setenv("LOGIN_MAX_ATTEMPTS_IP", "100")
+	t.Setenv("LOGIN_LOCKOUT_BASE", "1m")
+	t.Setenv("LOGIN_LOCKOUT_MAX", "1h")
+	ctx := context.Background()
+	throttle := NewLoginThrottle(NewMemoryThrottleStore(time.Hour))
+
+	if lockouts := failLogins(t, throttle, "User@Example.com", "10.0.0.1", 2); len(lockouts) != 0 {
+		t.Fatalf("locked out below the limit: %+v", lockouts)
+	}
+	if wait, _ := throttle.Check(ctx, "user@example.com", "10.0.0.1"); wait != 0 {
+		t.Fatalf("Check before lockout = %v, want 0", wait)
+	}
+
+	lockouts := failLogins(t, throttle, "user@example.com", "10.0.0.2", 1)
+	if len(lockouts) != 1 || lockouts[0] != (Lockout{Scope: "account", Duration: time.Minute}) {
+		t.Fatalf("first lockout = %+v, want one account lockout of 1m", lockouts)
+	}
+	wait, err := throttle.Check(ctx, " USER@example.com ", "10.0.0.3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if wait <= 0 || wait > time.Minute {
+		t.Fatalf("Check during lockout = %v, want up to 1m from any IP", wait)
+	}
+
+	lockouts = failLogins(t, throttle, "user@example.com", "10.0.0.1", 3)
+	if len(lockouts) != 1 || lockouts[0].Duration != 2*time.Minute {
+		t.Fatalf("second lockout = %+v, want 2m", lockouts)
+	}
+
+	if err := throttle.RecordSuccess(ctx, "user@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	if wait, _ := throttle.Check(ctx, "user@example.com", "10.0.0.1"); wait != 0 {
+		t.Fatalf("Check after successful login = %v, want 0", wait)
+	}
+	lockouts = failLogins(t, throttle, "user@example.com", "10.0.0.1", 3)
+	if len(lockouts) != 1 || lockouts[0].Duration != time.Minute {
+		t.Fatalf("lockout after a successful login = %+v, want escalation reset to 1m", lockouts)
+	}
+}
+
+func TestLoginThrottleIPLockout(t *testing.T) {
+	t.Setenv("LOGIN_MAX_ATTEMPTS_ACCOUNT", "100")
+	t.Setenv("LOGIN_MAX_ATTEMPTS_IP", "5")
+	t.Setenv("LOGIN_LOCKOUT_BASE", "1m")
+	ctx := context.Background()
+	throttle := NewLoginThrottle(NewMemoryThrottleStore(time.Hour))
+
+	var lockouts []Lockout
+	for i := 0; i < 5; i++ {
+		lockouts = append(lockouts, failLogins(t, throttle, fmt.Sprintf("user%d@example.com", i), "10.0.0.1", 1)...)
+	}
+	if len(lockouts) != 1 || lockouts[0].Scope != "ip" {
+		t.Fatalf("lockouts = %+v, want one ip lockout", lockouts)
+	}
+	if wait, _ := throttle.Check(ctx, "someone@example.com", "10.0.0.1"); wait <= 0 {
+		t.Fatal("locked IP can still try other accounts")
+	}
+	if wait, _ := throttle.Check(ctx, "someone@example.com", "10.0.0.2"); wait != 0 {
+		t.Fatalf("another IP is locked too: %v", wait)
+	}
+}
@@ -0,0 +1,188 @@
+package auth
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/planer/backend/internal/database"
+	"github.com/planer/backend/internal/mailer"
+)
+
+const (
+	verificationSubject = "email_verification"
+	emailChangeSubject  = "email_change"
+)
+
+var (
+	ErrVerificationInvalid   = errors.New("ссылка подтверждения недействительна или устарела")
+	ErrEmailAlreadyVerified  = errors.New("email уже подтверждён")
+	ErrVerificationThrottled = errors.New("письмо уже отправлено, повторите попытку позже")
+)
+
+func verificationTTL() time.Duration {
+	return getEnvDuration("EMAIL_VERIFICATION_TTL", 24*time.Hour)
+}
+
+func verificationResendInterval() time.Duration {
+	return getEnvDuration("EMAIL_RESEND_INTERVAL", time.Minute)
+}
+
+func verificationAudience() string {
+	return tokenAudience() + ":verify-email"
+}
+
+func RequireVerifiedForAI() bool {
+	return os.Getenv("REQUIRE_VERIFIED_FOR_AI") == "true"
+}
+
+func appLink(path string, token string) string {
+	base := strings.TrimRight(getEnv("APP_BASE_URL", "http://localhost:3000"), "/")
+	return base + path + "?token=" + url.QueryEscape(token)
+}
+
+func generateVerificationToken(user *User, subject string) (string, error) {
+	now := time.Now()
+	claims := &Claims{
+		UserID: user.ID,
+		Email:  user.Email,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    tokenIssuer(),
+			Audience:  jwt.ClaimStrings{verificationAudience()},
+			ExpiresAt: jwt.NewNumericDate(now.Add(verificationTTL())),
+			NotBefore: jwt.NewNumericDate(now),
+			IssuedAt:  jwt.NewNumericDate(now),
+			Subject:   subject,
+			ID:        uuid.New().String(),
+		},
+	}
+	return activeKeys().sign(claims)
+}
+
+func SendVerificationEmail(ctx context.Context, user *User) (time.Duration, error) {
+	if user.EmailVerified {
+		return 0, ErrEmailAlreadyVerified
+	}
+
+	retryAfter, err := reserveVerificationSend(ctx, user.ID)
+	if err != nil {
+		return retryAfter, err
+	}
+
+	token, err := generateVerificationToken(user, verificationSubject)
+	if err != nil {
+		return 0, err
+	}
+
+	return 0, mailer.Send(ctx, mailer.Message{
+		To:      user.Email,
+		Subject: "Подтверждение email",
+		Text: fmt.Sprintf("Здравствуйте, %s!\n\nЧтобы подтвердить адрес электронной почты, перейдите по ссылке:\n%s\n\nСсылка действительна %s. Если вы не регистрировались, просто проигнорируйте это письмо.\n",
+			user.Name, appLink("/verify-email", token), verificationTTL()),
+	})
+}
+
+func reserveVerificationSend(ctx context.Context, userID int) (time.Duration, error) {
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
+
+	now := time.Now().UTC()
+	interval := verificationResendInterval()
+	result, err := database.DB.ExecContext(ctx,
+		`UPDATE users SET verification_sent_at = $1
+		WHERE id = $2 AND email_verified_at IS NULL
+		AND (verification_sent_at IS NULL OR verification_sent_at < $3)`,
+		now, userID, now.Add(-interval),
+	)
+	if err != nil {
+		return 0, err
+	}
+	if affected, err := result.RowsAffected(); err != nil || affected > 0 {
+		return 0, err
+	}
+
+	var verifiedAt, sentAt sql.NullTime
+	err = database.DB.QueryRowContext(ctx,
+		"SELECT email_verified_at, verification_sent_at FROM users WHERE id = $1",
+		userID,
+	).Scan(&verifiedAt, &sentAt)
+	if err != nil {
+		return 0, err
+	}
+	if verifiedAt.Valid {
+		return 0, ErrEmailAlreadyVerified
+	}
+
+	retryAfter := interval
+	if sentAt.Valid {
+		retryAfter = time.Until(sentAt.Time.Add(interval))
+	}
+	if retryAfter < time.Second {
+		retryAfter = time.Second
+	}
+	return retryAfter, ErrVerificationThrottled
+}
+
+func VerifyEmail(ctx context.Context, token string) (*User, error) {
+	claims := &Claims{}
+	if err := activeKeys().parse(token, claims, verificationAudience()); err != nil {
+		return nil, ErrVerificationInvalid
+	}
+	if claims.Subject == emailChangeSubject {
+		user, oldEmail, err := confirmEmailChange(ctx, claims.UserID, claims.Email)
+		if err != nil {
+			return nil, err
+		}
+		if err := NotifyEmailChanged(ctx, user, oldEmail); err != nil {
+			log.Printf("Ошибка при отправке уведомления о смене email: %v", err)
+		}
+		return user, nil
+	}
+	if claims.Subject != verificationSubject {
+		return nil, ErrVerificationInvalid
+	}
+
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
+
+	result, err := database.DB.ExecContext(ctx,
+		`UPDATE users SET email_verified_at = CURRENT_TIMESTAMP, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1 AND email = $2 AND email_verified_at IS NULL`,
+		claims.UserID, claims.Email,
+	)
+	if err != nil {
+		return nil, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := GetUserByID(ctx, claims.UserID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrVerificationInvalid
+	}
+	if err != nil {
+		return nil, err
+	}
+	if affected == 0 {
+		if user.EmailVerified && strings.EqualFold(user.Email, claims.Email) {
+			return user, ErrEmailAlreadyVerified
+		}
+		return nil, ErrVerificationInvalid
+	}
+	return user, nil
+}
+
+func retryAfterSeconds(d time.Duration) string {
+	return strconv.Itoa(int((d + time.Second - 1) / time.Second))
+}
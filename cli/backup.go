@@ -0,0 +1,490 @@
+package cli
+
+import (
+	"archive/zip"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/planer/backend/internal/database"
+	"github.com/planer/backend/internal/storage"
+)
+
+const backupFormatVersion = 1
+
+type columnKind int
+
+const (
+	kindInt columnKind = iota
+	kindFloat
+	kindText
+	kindBool
+	kindTime
+	kindStrings
+)
+
+type column struct {
+	name string
+	kind columnKind
+}
+
+type table struct {
+	name     string
+	serial   bool
+	columns  []column
+	deferred string
+}
+
+var backupTables = []table{
+	{name: "users", serial: true, columns: []column{
+		{"id", kindInt}, {"name", kindText}, {"email", kindText}, {"password_hash", kindText}, {"role", kindText},
+		{"avatar_url", kindText}, {"language", kindText}, {"units", kindText},
+		{"pending_email", kindText},
+		{"email_verified_at", kindTime}, {"verification_sent_at", kindTime}, {"blocked_at", kindTime},
+		{"deletion_scheduled_at", kindTime}, {"created_at", kindTime}, {"updated_at", kindTime},
+	}},
+	{name: "user_identities", columns: []column{
+		{"provider", kindText}, {"subject", kindText}, {"user_id", kindInt}, {"email", kindText},
+		{"created_at", kindTime}, {"last_login_at", kindTime},
+	}},
+	{name: "api_keys", serial: true, columns: []column{
+		{"id", kindInt}, {"user_id", kindInt}, {"name", kindText}, {"prefix", kindText}, {"key_hash", kindText},
+		{"scopes", kindStrings}, {"expires_at", kindTime}, {"last_used_at", kindTime}, {"revoked_at", kindTime},
+		{"created_at", kindTime},
+	}},
+	{name: "projects", serial: true, columns: []column{
+		{"id", kindInt}, {"user_id", kindInt}, {"name", kindText}, {"address", kindText}, {"description", kindText},
+		{"created_at", kindTime}, {"updated_at", kindTime},
+	}},
+	{name: "project_designers", columns: []column{
+		{"project_id", kindInt}, {"user_id", kindInt}, {"created_at", kindTime},
+	}},
+	{name: "plan_folders", serial: true, deferred: "parent_id", columns: []column{
+		{"id", kindInt}, {"user_id", kindInt}, {"parent_id", kindInt}, {"name", kindText}, {"created_at", kindTime},
+	}},
+	{name: "apartment_plans", serial: true, columns: []column{
+		{"id", kindInt}, {"user_id", kindInt}, {"title", kindText}, {"area", kindFloat}, {"rooms", kindInt},
+		{"style", kindText}, {"features", kindStrings}, {"floor_plan", kindText}, {"render_3d", kindText},
+		{"project_id", kindInt}, {"folder_id", kindInt}, {"is_favorite", kindBool}, {"deleted_at", kindTime},
+		{"created_at", kindTime}, {"updated_at", kindTime},
+	}},
+	{name: "plan_rooms", serial: true, columns: []column{
+		{"id", kindInt}, {"plan_id", kindInt}, {"position", kindInt}, {"name", kindText}, {"area", kindFloat},
+		{"width", kindFloat}, {"height", kindFloat}, {"x", kindFloat}, {"y", kindFloat},
+	}},
+	{name: "plan_tags", columns: []column{
+		{"plan_id", kindInt}, {"tag", kindText},
+	}},
+	{name: "plan_revisions", serial: true, columns: []column{
+		{"id", kindInt}, {"plan_id", kindInt}, {"revision", kindInt}, {"author_id", kindInt}, {"summary", kindText},
+		{"document", kindText}, {"created_at", kindTime},
+	}},
+	{name: "interior_designs", serial: true, columns: []column{
+		{"id", kindInt}, {"user_id", kindInt}, {"project_id", kindInt}, {"plan_id", kindInt}, {"room_name", kindText},
+		{"room_type", kindText}, {"style", kindText}, {"image_url", kindText}, {"prompt", kindText}, {"model", kindText},
+		{"parameters", kindText}, {"deleted_at", kindTime}, {"created_at", kindTime},
+	}},
+	{name: "project_notes", serial: true, columns: []column{
+		{"id", kindInt}, {"project_id", kindInt}, {"author_id", kindInt}, {"body", kindText},
+		{"created_at", kindTime}, {"updated_at", kindTime},
+	}},
+	{name: "project_attachments", serial: true, columns: []column{
+		{"id", kindInt}, {"project_id", kindInt}, {"uploader_id", kindInt}, {"filename", kindText},
+		{"content_type", kindText}, {"size", kindInt}, {"url", kindText}, {"created_at", kindTime},
+	}},
+	{name: "assets", columns: []column{
+		{"hash", kindText}, {"key", kindText}, {"content_type", kindText}, {"size", kindInt}, {"ref_count", kindInt},
+		{"unreferenced_since", kindTime}, {"created_at", kindTime},
+	}},
+	{name: "audit_log", serial: true, columns: []column{
+		{"id", kindInt}, {"actor_id", kindInt}, {"action", kindText}, {"target_type", kindText}, {"target_id", kindText},
+		{"ip", kindText}, {"details", kindText}, {"created_at", kindTime},
+	}},
+}
+
+type backupManifest struct {
+	FormatVersion int            `json:"format_version"`
+	SchemaVersion int            `json:"schema_version"`
+	Driver        string         `json:"driver"`
+	CreatedAt     string         `json:"created_at"`
+	Tables        map[string]int `json:"tables"`
+	Files         int            `json:"files"`
+}
+
+func (t table) columnNames() []string {
+	names := make([]string, len(t.columns))
+	for i, col := range t.columns {
+		names[i] = col.name
+	}
+	return names
+}
+
+func newScanTarget(kind columnKind) interface{} {
+	switch kind {
+	case kindInt:
+		return new(sql.NullInt64)
+	case kindFloat:
+		return new(sql.NullFloat64)
+	case kindBool:
+		return new(sql.NullBool)
+	case kindTime:
+		return new(sql.NullTime)
+	case kindStrings:
+		values := make([]string, 0)
+		return &values
+	default:
+		return new(sql.NullString)
+	}
+}
+
+func exportValue(target interface{}) interface{} {
+	switch v := target.(type) {
+	case *sql.NullInt64:
+		if v.Valid {
+			return v.Int64
+		}
+	case *sql.NullFloat64:
+		if v.Valid {
+			return v.Float64
+		}
+	case *sql.NullBool:
+		if v.Valid {
+			return v.Bool
+		}
+	case *sql.NullTime:
+		if v.Valid {
+			return v.Time.UTC().Format(time.RFC3339Nano)
+		}
+	case *sql.NullString:
+		if v.Valid {
+			return v.String
+		}
+	case *[]string:
+		return *v
+	}
+	return nil
+}
+
+func importValue(kind columnKind, raw json.RawMessage) (interface{}, error) {
+	if len(raw) == 0 || string(raw) == "null" {
+		return nil, nil
+	}
+
+	switch kind {
+	case kindInt:
+		var v int64
+		return v, json.Unmarshal(raw, &v)
+	case kindFloat:
+		var v float64
+		return v, json.Unmarshal(raw, &v)
+	case kindBool:
+		var v bool
+		return v, json.Unmarshal(raw, &v)
+	case kindTime:
+		var v string
+		if err := json.Unmarshal(raw, &v); err != nil {
+			return nil, err
+		}
+		return time.Parse(time.RFC3339Nano, v)
+	case kindStrings:
+		var v []string
+		if err := json.Unmarshal(raw, &v); err != nil {
+			return nil, err
+		}
+		return database.StringArray(&v), nil
+	default:
+		var v string
+		return v, json.Unmarshal(raw, &v)
+	}
+}
+
+func dumpTable(ctx context.Context, tx *sql.Tx, t table) ([]map[string]interface{}, error) {
+	rows, err := tx.QueryContext(ctx,
+		"SELECT "+strings.Join(t.columnNames(), ", ")+" FROM "+t.name+" ORDER BY "+t.columns[0].name,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	records := make([]map[string]interface{}, 0)
+	for rows.Next() {
+		targets := make([]interface{}, len(t.columns))
+		dest := make([]interface{}, len(t.columns))
+		for i, col := range t.columns {
+			targets[i] = newScanTarget(col.kind)
+			dest[i] = targets[i]
+			if col.kind == kindStrings {
+				dest[i] = database.StringArray(targets[i].(*[]string))
+			}
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return nil, err
+		}
+
+		record := make(map[string]interface{}, len(t.columns))
+		for i, col := range t.columns {
+			record[col.name] = exportValue(targets[i])
+		}
+		records = append(records, record)
+	}
+	return records, rows.Err()
+}
+
+func Backup(ctx context.Context, path string, withFiles bool) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	archive := zip.NewWriter(file)
+
+	manifest := backupManifest{
+		FormatVersion: backupFormatVersion,
+		SchemaVersion: database.SchemaVersion(),
+		Driver:        database.Driver,
+		CreatedAt:     time.Now().UTC().Format(time.RFC3339),
+		Tables:        make(map[string]int),
+	}
+
+	var options *sql.TxOptions
+	if !database.IsSQLite() {
+		options = &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true}
+	}
+	tx, err := database.DB.BeginTx(ctx, options)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var assetKeys []string
+	for _, t := range backupTables {
+		records, err := dumpTable(ctx, tx, t)
+		if err != nil {
+			return fmt.Errorf("таблица %s: %v", t.name, err)
+		}
+		manifest.Tables[t.name] = len(records)
+		if t.name == "assets" {
+			for _, record := range records {
+				assetKeys = append(assetKeys, record["key"].(string))
+			}
+		}
+		if err := writeArchiveJSON(archive, "tables/"+t.name+".json", records); err != nil {
+			return err
+		}
+	}
+
+	if withFiles {
+		for _, key := range assetKeys {
+			data, err := storage.Default.Get(ctx, key)
+			if err != nil {
+				log.Printf("Не удалось прочитать файл %s: %v", key, err)
+				continue
+			}
+			entry, err := archive.Create("files/" + key)
+			if err != nil {
+				return err
+			}
+			if _, err := entry.Write(data); err != nil {
+				return err
+			}
+			manifest.Files++
+		}
+	}
+
+	if err := writeArchiveJSON(archive, "manifest.json", manifest); err != nil {
+		return err
+	}
+	if err := archive.Close(); err != nil {
+		return err
+	}
+	return file.Close()
+}
+
+func writeArchiveJSON(archive *zip.Writer, name string, value interface{}) error {
+	entry, err := archive.Create(name)
+	if err != nil {
+		return err
+	}
+	encoder := json.NewEncoder(entry)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(value)
+}
+
+func readArchiveFile(file *zip.File) ([]byte, error) {
+	reader, err := file.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	return ioutil.ReadAll(io.LimitReader(reader, 1<<30))
+}
+
+func Restore(ctx context.Context, path string) error {
+	archive, err := zip.OpenReader(path)
+	if err != nil {
+		return err
+	}
+	defer archive.Close()
+
+	entries := make(map[string]*zip.File)
+	for _, file := range archive.File {
+		entries[file.Name] = file
+	}
+
+	manifestFile, ok := entries["manifest.json"]
+	if !ok {
+		return fmt.Errorf("в архиве нет manifest.json")
+	}
+	data, err := readArchiveFile(manifestFile)
+	if err != nil {
+		return err
+	}
+	var manifest backupManifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return err
+	}
+	if manifest.FormatVersion != backupFormatVersion {
+		return fmt.Errorf("неподдерживаемая версия формата архива: %d", manifest.FormatVersion)
+	}
+	if manifest.SchemaVersion > database.SchemaVersion() {
+		return fmt.Errorf("архив создан для схемы версии %d, текущая версия %d", manifest.SchemaVersion, database.SchemaVersion())
+	}
+
+	for _, t := range backupTables {
+		var count int
+		if err := database.DB.QueryRowContext(ctx, "SELECT COUNT(*) FROM "+t.name).Scan(&count); err != nil {
+			return err
+		}
+		if count > 0 {
+			return fmt.Errorf("восстановление возможно только в пустую базу, таблица %s содержит %d записей", t.name, count)
+		}
+	}
+
+	tx, err := database.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	contentTypes := make(map[string]string)
+	for _, t := range backupTables {
+		file, ok := entries["tables/"+t.name+".json"]
+		if !ok {
+			continue
+		}
+		data, err := readArchiveFile(file)
+		if err != nil {
+			return err
+		}
+		var records []map[string]json.RawMessage
+		if err := json.Unmarshal(data, &records); err != nil {
+			return fmt.Errorf("таблица %s: %v", t.name, err)
+		}
+		if err := restoreTable(ctx, tx, t, records); err != nil {
+			return fmt.Errorf("таблица %s: %v", t.name, err)
+		}
+		if t.name == "assets" {
+			for _, record := range records {
+				var key, contentType string
+				json.Unmarshal(record["key"], &key)
+				json.Unmarshal(record["content_type"], &contentType)
+				contentTypes[key] = contentType
+			}
+		}
+		log.Printf("Восстановлено записей в %s: %d", t.name, len(records))
+	}
+
+	if !database.IsSQLite() {
+		for _, t := range backupTables {
+			if !t.serial {
+				continue
+			}
+			_, err := tx.ExecContext(ctx, fmt.Sprintf(
+				"SELECT setval(pg_get_serial_sequence('%s', 'id'), COALESCE(MAX(id), 0) + 1, false) FROM %s",
+				t.name, t.name))
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	restored := 0
+	for name, file := range entries {
+		if !strings.HasPrefix(name, "files/") || file.FileInfo().IsDir() {
+			continue
+		}
+		data, err := readArchiveFile(file)
+		if err != nil {
+			return err
+		}
+		key := strings.TrimPrefix(name, "files/")
+		if err := storage.Default.Put(ctx, key, data, contentTypes[key]); err != nil {
+			return fmt.Errorf("файл %s: %v", key, err)
+		}
+		restored++
+	}
+	if restored > 0 {
+		log.Printf("Восстановлено файлов: %d", restored)
+	}
+
+	return nil
+}
+
+func restoreTable(ctx context.Context, tx *sql.Tx, t table, records []map[string]json.RawMessage) error {
+	for _, record := range records {
+		var names, placeholders []string
+		var args []interface{}
+		for _, col := range t.columns {
+			raw, ok := record[col.name]
+			if !ok || col.name == t.deferred {
+				continue
+			}
+			value, err := importValue(col.kind, raw)
+			if err != nil {
+				return fmt.Errorf("%s: %v", col.name, err)
+			}
+			args = append(args, value)
+			names = append(names, col.name)
+			placeholders = append(placeholders, fmt.Sprintf("$%d", len(args)))
+		}
+		insert := "INSERT INTO " + t.name + " (" + strings.Join(names, ", ") + ") VALUES (" + strings.Join(placeholders, ", ") + ")"
+		if _, err := tx.ExecContext(ctx, insert, args...); err != nil {
+			return err
+		}
+	}
+
+	if t.deferred == "" {
+		return nil
+	}
+	update := "UPDATE " + t.name + " SET " + t.deferred + " = $1 WHERE id = $2"
+	for _, record := range records {
+		value, err := importValue(kindInt, record[t.deferred])
+		if err != nil {
+			return fmt.Errorf("%s: %v", t.deferred, err)
+		}
+		if value == nil {
+			continue
+		}
+		id, err := importValue(kindInt, record["id"])
+		if err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, update, value, id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,81 @@
+package cli
+
+import (
+	"context"
+	"flag"
+	"fmt"
+
+	"github.com/planer/backend/internal/auth"
+	"github.com/planer/backend/internal/database"
+	"github.com/planer/backend/internal/storage"
+)
+
+const usage = `Команды:
+  backup [-files] <archive.zip>   выгрузить пользователей, планы и ссылки на файлы в архив
+  restore <archive.zip>           восстановить архив в пустую базу данных
+  seed                            создать демо-пользователей и галерею планов
+  keygen [-alg EdDSA] <key.pem>   создать закрытый ключ для подписи JWT (RS256 или EdDSA)
+  role <email> <role>             назначить роль пользователю (client, designer, admin)`
+
+func IsCommand(name string) bool {
+	switch name {
+	case "backup", "restore", "seed", "keygen", "role", "help":
+		return true
+	}
+	return false
+}
+
+func Run(args []string) error {
+	if len(args) == 0 || args[0] == "help" {
+		fmt.Println(usage)
+		return nil
+	}
+
+	flags := flag.NewFlagSet(args[0], flag.ContinueOnError)
+	withFiles := flags.Bool("files", false, "включить в архив содержимое файлов")
+	algorithm := flags.String("alg", "EdDSA", "алгоритм ключа")
+	if err := flags.Parse(args[1:]); err != nil {
+		return err
+	}
+
+	if args[0] == "keygen" {
+		if flags.NArg() != 1 {
+			return fmt.Errorf("укажите путь к файлу ключа\n%s", usage)
+		}
+		return GenerateKey(*algorithm, flags.Arg(0))
+	}
+
+	database.InitDB()
+	defer database.CloseDB()
+	if database.MockMode {
+		return fmt.Errorf("команда %s требует подключения к базе данных", args[0])
+	}
+	storage.InitStorage()
+
+	ctx := context.Background()
+	switch args[0] {
+	case "backup":
+		if flags.NArg() != 1 {
+			return fmt.Errorf("укажите путь к архиву\n%s", usage)
+		}
+		return Backup(ctx, flags.Arg(0), *withFiles)
+	case "restore":
+		if flags.NArg() != 1 {
+			return fmt.Errorf("укажите путь к архиву\n%s", usage)
+		}
+		return Restore(ctx, flags.Arg(0))
+	case "seed":
+		return Seed(ctx)
+	case "role":
+		if flags.NArg() != 2 {
+			return fmt.Errorf("укажите email и роль\n%s", usage)
+		}
+		if err := auth.SetUserRoleByEmail(ctx, flags.Arg(0), flags.Arg(1)); err != nil {
+			return err
+		}
+		fmt.Printf("Пользователю %s назначена роль %s\n", flags.Arg(0), flags.Arg(1))
+		return nil
+	default:
+		return fmt.Errorf("неизвестная команда %s\n%s", args[0], usage)
+	}
+}
@@ -0,0 +1,46 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"log"
+
+	"github.com/planer/backend/internal/auth"
+	"github.com/planer/backend/internal/planner"
+)
+
+const demoPassword = "demo12345"
+
+var demoUsers = []struct {
+	Name  string
+	Email string
+	Role  string
+}{
+	{Name: "Анна Демо", Email: "demo@planer.local", Role: auth.RoleClient},
+	{Name: "Дизайнер Демо", Email: "designer@planer.local", Role: auth.RoleDesigner},
+}
+
+func Seed(ctx context.Context) error {
+	for _, demo := range demoUsers {
+		user, err := auth.RegisterUser(ctx, demo.Name, demo.Email, demoPassword)
+		if errors.Is(err, auth.ErrEmailExists) {
+			log.Printf("Пользователь %s уже существует, пропускаем", demo.Email)
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if demo.Role != auth.RoleClient {
+			if err := auth.SetUserRole(ctx, user.ID, demo.Role); err != nil {
+				return err
+			}
+		}
+
+		created, err := planner.SeedSamplePlans(ctx, user.ID)
+		if err != nil {
+			return err
+		}
+		log.Printf("Создан пользователь %s (пароль %s), планов: %d", demo.Email, demoPassword, created)
+	}
+	return nil
+}
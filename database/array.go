@@ -0,0 +1,49 @@
+package database
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+
+	"github.com/lib/pq"
+)
+
+type jsonStringArray struct {
+	a *[]string
+}
+
+func (j jsonStringArray) Value() (driver.Value, error) {
+	if *j.a == nil {
+		return nil, nil
+	}
+	data, err := json.Marshal(*j.a)
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+func (j jsonStringArray) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case nil:
+		*j.a = nil
+		return nil
+	case string:
+		return json.Unmarshal([]byte(v), j.a)
+	case []byte:
+		return json.Unmarshal(v, j.a)
+	default:
+		return fmt.Errorf("не удалось преобразовать %T в []string", src)
+	}
+}
+
+func StringArray(a *[]string) interface {
+	driver.Valuer
+	sql.Scanner
+} {
+	if IsSQLite() {
+		return jsonStringArray{a: a}
+	}
+	return pq.Array(a)
+}
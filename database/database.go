@@ -1,16 +1,27 @@
 package database
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"log"
 	"os"
+	"strconv"
+	"time"
 
 	_ "github.com/lib/pq"
+	_ "modernc.org/sqlite"
+)
+
+const (
+	DriverPostgres = "postgres"
+	DriverSQLite   = "sqlite"
 )
 
 var DB *sql.DB
 var MockMode bool = false
+var Driver string = DriverPostgres
 
 func InitDB() {
 	if os.Getenv("MOCK_DB") == "true" {
@@ -19,72 +30,121 @@ func InitDB() {
 		return
 	}
 
-	host := getEnv("DB_HOST", "localhost")
-	port := getEnv("DB_PORT", "5432")
-	user := getEnv("DB_USER", "postgres")
-	password := getEnv("DB_PASSWORD", "postgres")
-	dbname := getEnv("DB_NAME", "planer")
+	Driver = getEnv("DB_DRIVER", DriverPostgres)
+	QueryTimeout = getEnvDuration("DB_QUERY_TIMEOUT", QueryTimeout)
+
+	var connStr string
+	switch Driver {
+	case DriverPostgres:
+		host := getEnv("DB_HOST", "localhost")
+		port := getEnv("DB_PORT", "5432")
+		user := getEnv("DB_USER", "postgres")
+		password := getEnv("DB_PASSWORD", "postgres")
+		dbname := getEnv("DB_NAME", "planer")
 
-	connStr := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=disable",
-		host, port, user, password, dbname)
+		connStr = fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=disable",
+			host, port, user, password, dbname)
+	case DriverSQLite:
+		path := getEnv("SQLITE_PATH", "planer.db")
+		connStr = fmt.Sprintf("file:%s?_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)", path)
+		log.Printf("Используем встроенную базу данных SQLite: %s", path)
+	default:
+		log.Fatalf("Неизвестный драйвер базы данных: %s", Driver)
+	}
 
 	var err error
-	DB, err = sql.Open("postgres", connStr)
+	DB, err = sql.Open(Driver, connStr)
 	if err != nil {
-		log.Printf("Предупреждение: Не удалось подключиться к базе данных: %v", err)
-		log.Println("Переключение в режим без базы данных")
-		MockMode = true
+		failOrFallback("Не удалось подключиться к базе данных", err)
 		return
 	}
 
-	err = DB.Ping()
+	configurePool()
+
+	err = pingWithRetry()
 	if err != nil {
-		log.Printf("Предупреждение: Не удалось проверить соединение с базой данных: %v", err)
-		log.Println("Переключение в режим без базы данных")
-		MockMode = true
+		failOrFallback("Не удалось проверить соединение с базой данных", err)
 		return
 	}
 
 	log.Println("Успешное подключение к базе данных")
 
-	createTables()
+	runMigrations()
 }
 
-func createTables() {
-	_, err := DB.Exec(`
-		CREATE TABLE IF NOT EXISTS users (
-			id SERIAL PRIMARY KEY,
-			name VARCHAR(100) NOT NULL,
-			email VARCHAR(100) UNIQUE NOT NULL,
-			password_hash VARCHAR(100) NOT NULL,
-			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
-		)
-	`)
-	if err != nil {
-		log.Fatalf("Не удалось создать таблицу users: %v", err)
+func configurePool() {
+	if Driver == DriverSQLite {
+		DB.SetMaxOpenConns(1)
+		return
 	}
 
-	_, err = DB.Exec(`
-		CREATE TABLE IF NOT EXISTS apartment_plans (
-			id SERIAL PRIMARY KEY,
-			user_id INTEGER REFERENCES users(id),
-			title VARCHAR(100) NOT NULL,
-			area FLOAT NOT NULL,
-			rooms INTEGER NOT NULL,
-			style VARCHAR(50) NOT NULL,
-			features TEXT[],
-			floor_plan TEXT,
-			render_3d TEXT,
-			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
-		)
-	`)
-	if err != nil {
-		log.Fatalf("Не удалось создать таблицу apartment_plans: %v", err)
+	DB.SetMaxOpenConns(getEnvInt("DB_MAX_OPEN_CONNS", 25))
+	DB.SetMaxIdleConns(getEnvInt("DB_MAX_IDLE_CONNS", 5))
+	DB.SetConnMaxLifetime(getEnvDuration("DB_CONN_MAX_LIFETIME", 30*time.Minute))
+	DB.SetConnMaxIdleTime(getEnvDuration("DB_CONN_MAX_IDLE_TIME", 5*time.Minute))
+}
+
+func pingWithRetry() error {
+	retries := getEnvInt("DB_CONNECT_RETRIES", 5)
+	backoff := getEnvDuration("DB_CONNECT_BACKOFF", time.Second)
+	maxBackoff := getEnvDuration("DB_CONNECT_MAX_BACKOFF", 30*time.Second)
+
+	var err error
+	for attempt := 1; ; attempt++ {
+		err = DB.Ping()
+		if err == nil || attempt > retries {
+			return err
+		}
+
+		log.Printf("База данных недоступна (попытка %d из %d): %v. Повтор через %s", attempt, retries+1, err, backoff)
+		time.Sleep(backoff)
+
+		backoff *= 2
+		if backoff > maxBackoff {
+			backoff = maxBackoff
+		}
+	}
+}
+
+func failOrFallback(message string, err error) {
+	if getEnv("DB_REQUIRED", "false") == "true" {
+		log.Fatalf("%s: %v", message, err)
+	}
+
+	log.Printf("Предупреждение: %s: %v", message, err)
+	log.Println("Переключение в режим без базы данных")
+	MockMode = true
+}
+
+func Ping(ctx context.Context) error {
+	if MockMode {
+		return nil
+	}
+	if DB == nil {
+		return errors.New("база данных не инициализирована")
 	}
+	return DB.PingContext(ctx)
+}
 
-	log.Println("Таблицы успешно созданы")
+type PoolStats struct {
+	OpenConnections int   `json:"open_connections"`
+	InUse           int   `json:"in_use"`
+	Idle            int   `json:"idle"`
+	WaitCount       int64 `json:"wait_count"`
+}
+
+func Stats() PoolStats {
+	stats := DB.Stats()
+	return PoolStats{
+		OpenConnections: stats.OpenConnections,
+		InUse:           stats.InUse,
+		Idle:            stats.Idle,
+		WaitCount:       stats.WaitCount,
+	}
+}
+
+func IsSQLite() bool {
+	return Driver == DriverSQLite
 }
 
 func CloseDB() {
@@ -101,3 +161,19 @@ func getEnv(key, defaultValue string) string {
 	}
 	return value
 }
+
+func getEnvInt(key string, defaultValue int) int {
+	value, err := strconv.Atoi(os.Getenv(key))
+	if err != nil {
+		return defaultValue
+	}
+	return value
+}
+
+func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
+	value, err := time.ParseDuration(os.Getenv(key))
+	if err != nil {
+		return defaultValue
+	}
+	return value
+}
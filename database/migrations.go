@@ -0,0 +1,593 @@
+package database
+
+import (
+	"log"
+)
+
+type migration struct {
+	version  int
+	name     string
+	postgres string
+	sqlite   string
+}
+
+var migrations = []migration{
+	{
+		version: 1,
+		name:    "users",
+		postgres: `
+			CREATE TABLE IF NOT EXISTS users (
+				id SERIAL PRIMARY KEY,
+				name VARCHAR(100) NOT NULL,
+				email VARCHAR(100) UNIQUE NOT NULL,
+				password_hash VARCHAR(100) NOT NULL,
+				created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+				updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+			)`,
+		sqlite: `
+			CREATE TABLE IF NOT EXISTS users (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				name VARCHAR(100) NOT NULL,
+				email VARCHAR(100) UNIQUE NOT NULL,
+				password_hash VARCHAR(100) NOT NULL,
+				created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+				updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+			)`,
+	},
+	{
+		version: 2,
+		name:    "apartment_plans",
+		postgres: `
+			CREATE TABLE IF NOT EXISTS apartment_plans (
+				id SERIAL PRIMARY KEY,
+				user_id INTEGER REFERENCES users(id),
+				title VARCHAR(100) NOT NULL,
+				area FLOAT NOT NULL,
+				rooms INTEGER NOT NULL,
+				style VARCHAR(50) NOT NULL,
+				features TEXT[],
+				floor_plan TEXT,
+				render_3d TEXT,
+				created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+				updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+			)`,
+		sqlite: `
+			CREATE TABLE IF NOT EXISTS apartment_plans (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				user_id INTEGER REFERENCES users(id),
+				title VARCHAR(100) NOT NULL,
+				area FLOAT NOT NULL,
+				rooms INTEGER NOT NULL,
+				style VARCHAR(50) NOT NULL,
+				features TEXT,
+				floor_plan TEXT,
+				render_3d TEXT,
+				created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+				updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+			)`,
+	},
+	{
+		version: 3,
+		name:    "plan_rooms",
+		postgres: `
+			CREATE TABLE IF NOT EXISTS plan_rooms (
+				id SERIAL PRIMARY KEY,
+				plan_id INTEGER NOT NULL REFERENCES apartment_plans(id) ON DELETE CASCADE,
+				position INTEGER NOT NULL,
+				name VARCHAR(100) NOT NULL,
+				area FLOAT NOT NULL,
+				width FLOAT NOT NULL,
+				height FLOAT NOT NULL,
+				x FLOAT NOT NULL,
+				y FLOAT NOT NULL
+			);
+			CREATE INDEX IF NOT EXISTS plan_rooms_plan_id_idx ON plan_rooms(plan_id);
+			CREATE INDEX IF NOT EXISTS plan_rooms_name_area_idx ON plan_rooms(name, area)`,
+		sqlite: `
+			CREATE TABLE IF NOT EXISTS plan_rooms (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				plan_id INTEGER NOT NULL REFERENCES apartment_plans(id) ON DELETE CASCADE,
+				position INTEGER NOT NULL,
+				name VARCHAR(100) NOT NULL,
+				area FLOAT NOT NULL,
+				width FLOAT NOT NULL,
+				height FLOAT NOT NULL,
+				x FLOAT NOT NULL,
+				y FLOAT NOT NULL
+			);
+			CREATE INDEX IF NOT EXISTS plan_rooms_plan_id_idx ON plan_rooms(plan_id);
+			CREATE INDEX IF NOT EXISTS plan_rooms_name_area_idx ON plan_rooms(name, area)`,
+	},
+	{
+		version: 4,
+		name:    "plan_revisions",
+		postgres: `
+			CREATE TABLE IF NOT EXISTS plan_revisions (
+				id SERIAL PRIMARY KEY,
+				plan_id INTEGER NOT NULL REFERENCES apartment_plans(id) ON DELETE CASCADE,
+				revision INTEGER NOT NULL,
+				author_id INTEGER REFERENCES users(id),
+				summary TEXT NOT NULL DEFAULT '',
+				document TEXT NOT NULL,
+				created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+				UNIQUE (plan_id, revision)
+			)`,
+		sqlite: `
+			CREATE TABLE IF NOT EXISTS plan_revisions (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				plan_id INTEGER NOT NULL REFERENCES apartment_plans(id) ON DELETE CASCADE,
+				revision INTEGER NOT NULL,
+				author_id INTEGER REFERENCES users(id),
+				summary TEXT NOT NULL DEFAULT '',
+				document TEXT NOT NULL,
+				created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+				UNIQUE (plan_id, revision)
+			)`,
+	},
+	{
+		version: 5,
+		name:    "apartment_plans_deleted_at",
+		postgres: `
+			ALTER TABLE apartment_plans ADD COLUMN deleted_at TIMESTAMP;
+			CREATE INDEX IF NOT EXISTS apartment_plans_deleted_at_idx ON apartment_plans(deleted_at)`,
+	},
+	{
+		version: 6,
+		name:    "assets",
+		postgres: `
+			CREATE TABLE IF NOT EXISTS assets (
+				hash VARCHAR(64) PRIMARY KEY,
+				key TEXT NOT NULL UNIQUE,
+				content_type VARCHAR(100) NOT NULL,
+				size BIGINT NOT NULL,
+				ref_count INTEGER NOT NULL DEFAULT 0,
+				unreferenced_since TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+				created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+			);
+			CREATE INDEX IF NOT EXISTS assets_unreferenced_idx ON assets(unreferenced_since) WHERE ref_count <= 0`,
+	},
+	{
+		version: 7,
+		name:    "users_deletion_scheduled_at",
+		postgres: `
+			ALTER TABLE users ADD COLUMN deletion_scheduled_at TIMESTAMP`,
+	},
+	{
+		version: 8,
+		name:    "projects",
+		postgres: `
+			CREATE TABLE IF NOT EXISTS projects (
+				id SERIAL PRIMARY KEY,
+				user_id INTEGER NOT NULL REFERENCES users(id),
+				name VARCHAR(200) NOT NULL,
+				address TEXT NOT NULL DEFAULT '',
+				description TEXT NOT NULL DEFAULT '',
+				created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+				updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+			);
+			CREATE INDEX IF NOT EXISTS projects_user_id_idx ON projects(user_id);
+			ALTER TABLE apartment_plans ADD COLUMN project_id INTEGER REFERENCES projects(id) ON DELETE SET NULL;
+			CREATE INDEX IF NOT EXISTS apartment_plans_project_id_idx ON apartment_plans(project_id);
+			CREATE TABLE IF NOT EXISTS interior_designs (
+				id SERIAL PRIMARY KEY,
+				user_id INTEGER NOT NULL REFERENCES users(id),
+				project_id INTEGER REFERENCES projects(id) ON DELETE SET NULL,
+				room_type VARCHAR(100) NOT NULL,
+				style VARCHAR(50) NOT NULL,
+				image_url TEXT NOT NULL,
+				created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+			);
+			CREATE INDEX IF NOT EXISTS interior_designs_user_id_idx ON interior_designs(user_id);
+			CREATE INDEX IF NOT EXISTS interior_designs_project_id_idx ON interior_designs(project_id);
+			CREATE TABLE IF NOT EXISTS project_notes (
+				id SERIAL PRIMARY KEY,
+				project_id INTEGER NOT NULL REFERENCES projects(id) ON DELETE CASCADE,
+				author_id INTEGER REFERENCES users(id),
+				body TEXT NOT NULL,
+				created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+				updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+			);
+			CREATE TABLE IF NOT EXISTS project_attachments (
+				id SERIAL PRIMARY KEY,
+				project_id INTEGER NOT NULL REFERENCES projects(id) ON DELETE CASCADE,
+				uploader_id INTEGER REFERENCES users(id),
+				filename VARCHAR(255) NOT NULL,
+				content_type VARCHAR(100) NOT NULL,
+				size BIGINT NOT NULL,
+				url TEXT NOT NULL,
+				created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+			)`,
+		sqlite: `
+			CREATE TABLE IF NOT EXISTS projects (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				user_id INTEGER NOT NULL REFERENCES users(id),
+				name VARCHAR(200) NOT NULL,
+				address TEXT NOT NULL DEFAULT '',
+				description TEXT NOT NULL DEFAULT '',
+				created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+				updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+			);
+			CREATE INDEX IF NOT EXISTS projects_user_id_idx ON projects(user_id);
+			ALTER TABLE apartment_plans ADD COLUMN project_id INTEGER REFERENCES projects(id) ON DELETE SET NULL;
+			CREATE INDEX IF NOT EXISTS apartment_plans_project_id_idx ON apartment_plans(project_id);
+			CREATE TABLE IF NOT EXISTS interior_designs (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				user_id INTEGER NOT NULL REFERENCES users(id),
+				project_id INTEGER REFERENCES projects(id) ON DELETE SET NULL,
+				room_type VARCHAR(100) NOT NULL,
+				style VARCHAR(50) NOT NULL,
+				image_url TEXT NOT NULL,
+				created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+			);
+			CREATE INDEX IF NOT EXISTS interior_designs_user_id_idx ON interior_designs(user_id);
+			CREATE INDEX IF NOT EXISTS interior_designs_project_id_idx ON interior_designs(project_id);
+			CREATE TABLE IF NOT EXISTS project_notes (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				project_id INTEGER NOT NULL REFERENCES projects(id) ON DELETE CASCADE,
+				author_id INTEGER REFERENCES users(id),
+				body TEXT NOT NULL,
+				created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+				updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+			);
+			CREATE TABLE IF NOT EXISTS project_attachments (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				project_id INTEGER NOT NULL REFERENCES projects(id) ON DELETE CASCADE,
+				uploader_id INTEGER REFERENCES users(id),
+				filename VARCHAR(255) NOT NULL,
+				content_type VARCHAR(100) NOT NULL,
+				size BIGINT NOT NULL,
+				url TEXT NOT NULL,
+				created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+			)`,
+	},
+	{
+		version: 9,
+		name:    "interior_designs_history",
+		postgres: `
+			ALTER TABLE interior_designs ADD COLUMN prompt TEXT NOT NULL DEFAULT '';
+			ALTER TABLE interior_designs ADD COLUMN model VARCHAR(200) NOT NULL DEFAULT '';
+			ALTER TABLE interior_designs ADD COLUMN parameters TEXT;
+			ALTER TABLE interior_designs ADD COLUMN plan_id INTEGER REFERENCES apartment_plans(id) ON DELETE SET NULL;
+			ALTER TABLE interior_designs ADD COLUMN room_name VARCHAR(100) NOT NULL DEFAULT '';
+			ALTER TABLE interior_designs ADD COLUMN deleted_at TIMESTAMP;
+			CREATE INDEX IF NOT EXISTS interior_designs_plan_room_idx ON interior_designs(plan_id, room_name)`,
+	},
+	{
+		version: 10,
+		name:    "plan_organization",
+		postgres: `
+			CREATE TABLE IF NOT EXISTS plan_folders (
+				id SERIAL PRIMARY KEY,
+				user_id INTEGER NOT NULL REFERENCES users(id),
+				parent_id INTEGER REFERENCES plan_folders(id) ON DELETE CASCADE,
+				name VARCHAR(100) NOT NULL,
+				created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+			);
+			CREATE INDEX IF NOT EXISTS plan_folders_user_id_idx ON plan_folders(user_id);
+			ALTER TABLE apartment_plans ADD COLUMN folder_id INTEGER REFERENCES plan_folders(id) ON DELETE SET NULL;
+			ALTER TABLE apartment_plans ADD COLUMN is_favorite BOOLEAN NOT NULL DEFAULT FALSE;
+			CREATE TABLE IF NOT EXISTS plan_tags (
+				plan_id INTEGER NOT NULL REFERENCES apartment_plans(id) ON DELETE CASCADE,
+				tag VARCHAR(50) NOT NULL,
+				PRIMARY KEY (plan_id, tag)
+			);
+			CREATE INDEX IF NOT EXISTS plan_tags_tag_idx ON plan_tags(tag)`,
+		sqlite: `
+			CREATE TABLE IF NOT EXISTS plan_folders (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				user_id INTEGER NOT NULL REFERENCES users(id),
+				parent_id INTEGER REFERENCES plan_folders(id) ON DELETE CASCADE,
+				name VARCHAR(100) NOT NULL,
+				created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+			);
+			CREATE INDEX IF NOT EXISTS plan_folders_user_id_idx ON plan_folders(user_id);
+			ALTER TABLE apartment_plans ADD COLUMN folder_id INTEGER REFERENCES plan_folders(id) ON DELETE SET NULL;
+			ALTER TABLE apartment_plans ADD COLUMN is_favorite BOOLEAN NOT NULL DEFAULT FALSE;
+			CREATE TABLE IF NOT EXISTS plan_tags (
+				plan_id INTEGER NOT NULL REFERENCES apartment_plans(id) ON DELETE CASCADE,
+				tag VARCHAR(50) NOT NULL,
+				PRIMARY KEY (plan_id, tag)
+			);
+			CREATE INDEX IF NOT EXISTS plan_tags_tag_idx ON plan_tags(tag)`,
+	},
+	{
+		version: 11,
+		name:    "audit_log",
+		postgres: `
+			CREATE TABLE IF NOT EXISTS audit_log (
+				id BIGSERIAL PRIMARY KEY,
+				actor_id INTEGER,
+				action VARCHAR(50) NOT NULL,
+				target_type VARCHAR(50) NOT NULL DEFAULT '',
+				target_id VARCHAR(100) NOT NULL DEFAULT '',
+				ip VARCHAR(64) NOT NULL DEFAULT '',
+				details JSONB,
+				created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+			);
+			CREATE INDEX IF NOT EXISTS audit_log_actor_id_idx ON audit_log(actor_id);
+			CREATE INDEX IF NOT EXISTS audit_log_action_idx ON audit_log(action);
+			CREATE INDEX IF NOT EXISTS audit_log_target_idx ON audit_log(target_type, target_id);
+			CREATE INDEX IF NOT EXISTS audit_log_created_at_idx ON audit_log(created_at);
+			CREATE OR REPLACE FUNCTION audit_log_append_only() RETURNS trigger AS $$
+			BEGIN
+				RAISE EXCEPTION 'audit_log is append-only';
+			END;
+			$$ LANGUAGE plpgsql;
+			CREATE TRIGGER audit_log_append_only
+				BEFORE UPDATE OR DELETE ON audit_log
+				FOR EACH ROW EXECUTE FUNCTION audit_log_append_only()`,
+		sqlite: `
+			CREATE TABLE IF NOT EXISTS audit_log (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				actor_id INTEGER,
+				action VARCHAR(50) NOT NULL,
+				target_type VARCHAR(50) NOT NULL DEFAULT '',
+				target_id VARCHAR(100) NOT NULL DEFAULT '',
+				ip VARCHAR(64) NOT NULL DEFAULT '',
+				details TEXT,
+				created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+			);
+			CREATE INDEX IF NOT EXISTS audit_log_actor_id_idx ON audit_log(actor_id);
+			CREATE INDEX IF NOT EXISTS audit_log_action_idx ON audit_log(action);
+			CREATE INDEX IF NOT EXISTS audit_log_target_idx ON audit_log(target_type, target_id);
+			CREATE INDEX IF NOT EXISTS audit_log_created_at_idx ON audit_log(created_at);
+			CREATE TRIGGER IF NOT EXISTS audit_log_no_update BEFORE UPDATE ON audit_log
+			BEGIN
+				SELECT RAISE(ABORT, 'audit_log is append-only');
+			END;
+			CREATE TRIGGER IF NOT EXISTS audit_log_no_delete BEFORE DELETE ON audit_log
+			BEGIN
+				SELECT RAISE(ABORT, 'audit_log is append-only');
+			END`,
+	},
+	{
+		version: 12,
+		name:    "auth_sessions",
+		postgres: `
+			CREATE TABLE IF NOT EXISTS auth_sessions (
+				id VARCHAR(36) PRIMARY KEY,
+				user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+				user_agent TEXT NOT NULL DEFAULT '',
+				ip VARCHAR(64) NOT NULL DEFAULT '',
+				created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+				last_used_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+				expires_at TIMESTAMP NOT NULL,
+				revoked_at TIMESTAMP
+			);
+			CREATE INDEX IF NOT EXISTS auth_sessions_user_id_idx ON auth_sessions(user_id);
+			CREATE TABLE IF NOT EXISTS refresh_tokens (
+				token_hash VARCHAR(64) PRIMARY KEY,
+				session_id VARCHAR(36) NOT NULL REFERENCES auth_sessions(id) ON DELETE CASCADE,
+				expires_at TIMESTAMP NOT NULL,
+				used_at TIMESTAMP,
+				created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+			);
+			CREATE INDEX IF NOT EXISTS refresh_tokens_session_id_idx ON refresh_tokens(session_id)`,
+	},
+	{
+		version: 13,
+		name:    "users_email_verification",
+		postgres: `
+			ALTER TABLE users ADD COLUMN email_verified_at TIMESTAMP;
+			ALTER TABLE users ADD COLUMN verification_sent_at TIMESTAMP`,
+	},
+	{
+		version: 14,
+		name:    "password_resets",
+		postgres: `
+			CREATE TABLE IF NOT EXISTS password_resets (
+				token_hash VARCHAR(64) PRIMARY KEY,
+				user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+				expires_at TIMESTAMP NOT NULL,
+				used_at TIMESTAMP,
+				created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+			);
+			CREATE INDEX IF NOT EXISTS password_resets_user_id_idx ON password_resets(user_id)`,
+	},
+	{
+		version: 15,
+		name:    "user_identities",
+		postgres: `
+			CREATE TABLE IF NOT EXISTS user_identities (
+				provider VARCHAR(50) NOT NULL,
+				subject VARCHAR(255) NOT NULL,
+				user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+				email VARCHAR(100) NOT NULL DEFAULT '',
+				created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+				last_login_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+				PRIMARY KEY (provider, subject)
+			);
+			CREATE INDEX IF NOT EXISTS user_identities_user_id_idx ON user_identities(user_id)`,
+	},
+	{
+		version: 16,
+		name:    "api_keys",
+		postgres: `
+			CREATE TABLE IF NOT EXISTS api_keys (
+				id SERIAL PRIMARY KEY,
+				user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+				name VARCHAR(100) NOT NULL,
+				prefix VARCHAR(16) NOT NULL,
+				key_hash VARCHAR(64) NOT NULL UNIQUE,
+				scopes TEXT[] NOT NULL DEFAULT '{}',
+				expires_at TIMESTAMP,
+				last_used_at TIMESTAMP,
+				revoked_at TIMESTAMP,
+				created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+			);
+			CREATE INDEX IF NOT EXISTS api_keys_user_id_idx ON api_keys(user_id)`,
+		sqlite: `
+			CREATE TABLE IF NOT EXISTS api_keys (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+				name VARCHAR(100) NOT NULL,
+				prefix VARCHAR(16) NOT NULL,
+				key_hash VARCHAR(64) NOT NULL UNIQUE,
+				scopes TEXT NOT NULL DEFAULT '[]',
+				expires_at TIMESTAMP,
+				last_used_at TIMESTAMP,
+				revoked_at TIMESTAMP,
+				created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+			);
+			CREATE INDEX IF NOT EXISTS api_keys_user_id_idx ON api_keys(user_id)`,
+	},
+	{
+		version: 17,
+		name:    "user_roles",
+		postgres: `
+			ALTER TABLE users ADD COLUMN role VARCHAR(20) NOT NULL DEFAULT 'client';
+			ALTER TABLE users ADD COLUMN blocked_at TIMESTAMP;
+			CREATE TABLE IF NOT EXISTS project_designers (
+				project_id INTEGER NOT NULL REFERENCES projects(id) ON DELETE CASCADE,
+				user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+				created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+				PRIMARY KEY (project_id, user_id)
+			);
+			CREATE INDEX IF NOT EXISTS project_designers_user_id_idx ON project_designers(user_id)`,
+	},
+	{
+		version: 18,
+		name:    "users_profile",
+		postgres: `
+			ALTER TABLE users ADD COLUMN avatar_url TEXT;
+			ALTER TABLE users ADD COLUMN language VARCHAR(10) NOT NULL DEFAULT 'ru';
+			ALTER TABLE users ADD COLUMN units VARCHAR(10) NOT NULL DEFAULT 'metric'`,
+	},
+	{
+		version: 19,
+		name:    "users_pending_email",
+		postgres: `
+			ALTER TABLE users ADD COLUMN pending_email VARCHAR(100)`,
+	},
+	{
+		version: 20,
+		name:    "plan_search_vector",
+		postgres: `
+			ALTER TABLE apartment_plans ADD COLUMN search_vector tsvector;
+			CREATE OR REPLACE FUNCTION plan_search_document(plan_id INTEGER, title TEXT, style TEXT, features TEXT[], project_id INTEGER)
+			RETURNS tsvector AS $$
+				SELECT setweight(to_tsvector('russian', COALESCE(title, '')), 'A') ||
+					to_tsvector('russian', COALESCE(style, '') || ' ' || COALESCE(array_to_string(features, ' '), '')
+						|| ' ' || COALESCE((SELECT string_agg(r.name, ' ') FROM plan_rooms r WHERE r.plan_id = $1), '')
+						|| ' ' || COALESCE((SELECT string_agg(t.tag, ' ') FROM plan_tags t WHERE t.plan_id = $1), '')
+						|| ' ' || COALESCE((SELECT string_agg(n.body, ' ') FROM project_notes n WHERE n.project_id = $5), ''))
+			$$ LANGUAGE sql STABLE;
+			CREATE OR REPLACE FUNCTION apartment_plans_search_vector() RETURNS trigger AS $$
+			BEGIN
+				NEW.search_vector := plan_search_document(NEW.id, NEW.title, NEW.style, NEW.features, NEW.project_id);
+				RETURN NEW;
+			END;
+			$$ LANGUAGE plpgsql;
+			CREATE TRIGGER apartment_plans_search_vector
+				BEFORE INSERT OR UPDATE OF title, style, features, project_id ON apartment_plans
+				FOR EACH ROW EXECUTE FUNCTION apartment_plans_search_vector();
+			CREATE OR REPLACE FUNCTION refresh_plan_search_vector(target INTEGER) RETURNS void AS $$
+				UPDATE apartment_plans
+				SET search_vector = plan_search_document(id, title, style, features, project_id)
+				WHERE id = $1
+			$$ LANGUAGE sql;
+			CREATE OR REPLACE FUNCTION plan_children_search_vector() RETURNS trigger AS $$
+			BEGIN
+				IF TG_OP <> 'INSERT' THEN
+					PERFORM refresh_plan_search_vector(OLD.plan_id);
+				END IF;
+				IF TG_OP <> 'DELETE' THEN
+					PERFORM refresh_plan_search_vector(NEW.plan_id);
+				END IF;
+				RETURN NULL;
+			END;
+			$$ LANGUAGE plpgsql;
+			CREATE TRIGGER plan_rooms_search_vector
+				AFTER INSERT OR UPDATE OR DELETE ON plan_rooms
+				FOR EACH ROW EXECUTE FUNCTION plan_children_search_vector();
+			CREATE TRIGGER plan_tags_search_vector
+				AFTER INSERT OR UPDATE OR DELETE ON plan_tags
+				FOR EACH ROW EXECUTE FUNCTION plan_children_search_vector();
+			CREATE OR REPLACE FUNCTION project_notes_search_vector() RETURNS trigger AS $$
+			BEGIN
+				IF TG_OP <> 'INSERT' THEN
+					UPDATE apartment_plans
+					SET search_vector = plan_search_document(id, title, style, features, project_id)
+					WHERE project_id = OLD.project_id;
+				END IF;
+				IF TG_OP <> 'DELETE' THEN
+					UPDATE apartment_plans
+					SET search_vector = plan_search_document(id, title, style, features, project_id)
+					WHERE project_id = NEW.project_id;
+				END IF;
+				RETURN NULL;
+			END;
+			$$ LANGUAGE plpgsql;
+			CREATE TRIGGER project_notes_search_vector
+				AFTER INSERT OR UPDATE OR DELETE ON project_notes
+				FOR EACH ROW EXECUTE FUNCTION project_notes_search_vector();
+			UPDATE apartment_plans
+			SET search_vector = plan_search_document(id, title, style, features, project_id);
+			CREATE INDEX IF NOT EXISTS apartment_plans_search_vector_idx ON apartment_plans USING GIN (search_vector)`,
+		sqlite: `
+			SELECT 1`,
+	},
+}
+
+func runMigrations() {
+	_, err := DB.Exec(`
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version INTEGER PRIMARY KEY,
+			name VARCHAR(100) NOT NULL,
+			applied_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		)
+	`)
+	if err != nil {
+		log.Fatalf("Не удалось создать таблицу schema_migrations: %v", err)
+	}
+
+	applied := make(map[int]bool)
+	rows, err := DB.Query("SELECT version FROM schema_migrations")
+	if err != nil {
+		log.Fatalf("Не удалось прочитать список миграций: %v", err)
+	}
+	for rows.Next() {
+		var version int
+		if err := rows.Scan(&version); err != nil {
+			rows.Close()
+			log.Fatalf("Не удалось прочитать список миграций: %v", err)
+		}
+		applied[version] = true
+	}
+	rows.Close()
+
+	for _, m := range migrations {
+		if applied[m.version] {
+			continue
+		}
+
+		stmt := m.postgres
+		if IsSQLite() && m.sqlite != "" {
+			stmt = m.sqlite
+		}
+
+		tx, err := DB.Begin()
+		if err != nil {
+			log.Fatalf("Не удалось начать миграцию %d (%s): %v", m.version, m.name, err)
+		}
+		if _, err := tx.Exec(stmt); err != nil {
+			tx.Rollback()
+			log.Fatalf("Не удалось применить миграцию %d (%s): %v", m.version, m.name, err)
+		}
+		if _, err := tx.Exec("INSERT INTO schema_migrations (version, name) VALUES ($1, $2)", m.version, m.name); err != nil {
+			tx.Rollback()
+			log.Fatalf("Не удалось записать миграцию %d (%s): %v", m.version, m.name, err)
+		}
+		if err := tx.Commit(); err != nil {
+			log.Fatalf("Не удалось завершить миграцию %d (%s): %v", m.version, m.name, err)
+		}
+
+		log.Printf("Применена миграция %d: %s", m.version, m.name)
+	}
+
+	log.Println("Таблицы успешно созданы")
+}
+
+func SchemaVersion() int {
+	return migrations[len(migrations)-1].version
+}
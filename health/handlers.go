@@ -0,0 +1,95 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/planer/backend/internal/database"
+	"github.com/planer/backend/internal/planner"
+)
+
+const aiStatusTTL = time.Minute
+
+type ComponentStatus struct {
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+type ReadinessResponse struct {
+	Status   string              `json:"status"`
+	Database ComponentStatus     `json:"database"`
+	AI       ComponentStatus     `json:"ai"`
+	Pool     *database.PoolStats `json:"pool,omitempty"`
+}
+
+var (
+	aiMutex     sync.Mutex
+	aiStatus    ComponentStatus
+	aiCheckedAt time.Time
+)
+
+func HealthzHandler(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
+
+func ReadyzHandler(c *gin.Context) {
+	resp := ReadinessResponse{
+		Status:   "ok",
+		Database: checkDatabase(c.Request.Context()),
+		AI:       checkAI(c.Request.Context()),
+	}
+	if !database.MockMode && database.DB != nil {
+		stats := database.Stats()
+		resp.Pool = &stats
+	}
+
+	code := http.StatusOK
+	if resp.Database.Status == "down" {
+		resp.Status = "unavailable"
+		code = http.StatusServiceUnavailable
+	} else if resp.AI.Status == "degraded" {
+		resp.Status = "degraded"
+	}
+
+	c.JSON(code, resp)
+}
+
+func checkDatabase(ctx context.Context) ComponentStatus {
+	if database.MockMode {
+		return ComponentStatus{Status: "mock"}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 2*time.Second)
+	defer cancel()
+
+	if err := database.Ping(ctx); err != nil {
+		return ComponentStatus{Status: "down", Error: err.Error()}
+	}
+	return ComponentStatus{Status: "ok"}
+}
+
+func checkAI(ctx context.Context) ComponentStatus {
+	aiMutex.Lock()
+	defer aiMutex.Unlock()
+
+	if time.Since(aiCheckedAt) < aiStatusTTL {
+		return aiStatus
+	}
+
+	err := planner.NewAIPlanner().CheckStatus(ctx)
+	switch {
+	case errors.Is(err, planner.ErrAIDisabled):
+		aiStatus = ComponentStatus{Status: "disabled"}
+	case err != nil:
+		aiStatus = ComponentStatus{Status: "degraded", Error: err.Error()}
+	default:
+		aiStatus = ComponentStatus{Status: "ok"}
+	}
+	aiCheckedAt = time.Now()
+
+	return aiStatus
+}
@@ -0,0 +1,79 @@
+package mailer
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"mime"
+	"os"
+	"strconv"
+	"time"
+)
+
+type Message struct {
+	To      string
+	Subject string
+	Text    string
+}
+
+type Mailer interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+var Default Mailer = &LogMailer{From: "no-reply@planer.local"}
+
+func InitMailer() {
+	backend := getEnv("MAILER", "log")
+	from := getEnv("MAIL_FROM", "no-reply@planer.local")
+
+	switch backend {
+	case "log":
+		Default = &LogMailer{From: from}
+		log.Println("Почта: письма выводятся в журнал")
+	case "file":
+		dir := getEnv("MAIL_DIR", "./mail")
+		Default = &FileMailer{Dir: dir, From: from}
+		log.Printf("Почта: письма сохраняются в директорию %s", dir)
+	case "smtp":
+		port, err := strconv.Atoi(getEnv("SMTP_PORT", "587"))
+		if err != nil {
+			log.Fatalf("Некорректное значение SMTP_PORT: %v", err)
+		}
+		Default = &SMTPMailer{
+			Host:     getEnv("SMTP_HOST", "localhost"),
+			Port:     port,
+			Username: os.Getenv("SMTP_USERNAME"),
+			Password: os.Getenv("SMTP_PASSWORD"),
+			From:     from,
+		}
+		log.Printf("Почта: SMTP сервер %s:%d", getEnv("SMTP_HOST", "localhost"), port)
+	default:
+		log.Fatalf("Неизвестный тип почтового транспорта: %s", backend)
+	}
+}
+
+func Send(ctx context.Context, msg Message) error {
+	return Default.Send(ctx, msg)
+}
+
+func buildMessage(from string, msg Message) []byte {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "From: %s\r\n", from)
+	fmt.Fprintf(&buf, "To: %s\r\n", msg.To)
+	fmt.Fprintf(&buf, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", msg.Subject))
+	fmt.Fprintf(&buf, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	buf.WriteString("MIME-Version: 1.0\r\n")
+	buf.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	buf.WriteString("Content-Transfer-Encoding: 8bit\r\n\r\n")
+	buf.WriteString(msg.Text)
+	return buf.Bytes()
+}
+
+func getEnv(key, defaultValue string) string {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	return value
+}
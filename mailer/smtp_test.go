@@ -0,0 +1,201 @@
+package mailer
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"mime"
+	"net"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+type receivedMail struct {
+	auth string
+	from string
+	to   []string
+	data string
+}
+
+func startSMTPServer(t *testing.T) (string, int, <-chan receivedMail) {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	received := make(chan receivedMail, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		serveSMTP(textproto.NewConn(conn), received)
+	}()
+
+	addr := listener.Addr().(*net.TCPAddr)
+	return "localhost", addr.Port, received
+}
+
+func serveSMTP(conn *textproto.Conn, received chan<- receivedMail) {
+	var mail receivedMail
+	conn.PrintfLine("220 localhost ESMTP test")
+	for {
+		line, err := conn.ReadLine()
+		if err != nil {
+			return
+		}
+		verb, arg, _ := strings.Cut(line, " ")
+		switch strings.ToUpper(verb) {
+		case "EHLO", "HELO":
+			conn.PrintfLine("250-localhost")
+			conn.PrintfLine("250-8BITMIME")
+			conn.PrintfLine("250 AUTH PLAIN")
+		case "AUTH":
+			mail.auth = strings.TrimPrefix(arg, "PLAIN ")
+			conn.PrintfLine("235 2.7.0 Authentication successful")
+		case "MAIL":
+			mail.from = envelopeAddress(arg)
+			conn.PrintfLine("250 OK")
+		case "RCPT":
+			mail.to = append(mail.to, envelopeAddress(arg))
+			conn.PrintfLine("250 OK")
+		case "DATA":
+			conn.PrintfLine("354 End data with <CR><LF>.<CR><LF>")
+			lines, err := conn.ReadDotLines()
+			if err != nil {
+				return
+			}
+			mail.data = strings.Join(lines, "\n")
+			conn.PrintfLine("250 OK")
+		case "QUIT":
+			conn.PrintfLine("221 Bye")
+			received <- mail
+			return
+		default:
+			conn.PrintfLine("250 OK")
+		}
+	}
+}
+
+func envelopeAddress(arg string) string {
+	_, rest, _ := strings.Cut(arg, "<")
+	address, _, _ := strings.Cut(rest, ">")
+	return address
+}
+
+func waitMail(t *testing.T, received <-chan receivedMail) receivedMail {
+	t.Helper()
+
+	select {
+	case mail := <-received:
+		return mail
+	case <-time.After(5 * time.Second):
+		t.Fatal("SMTP server received nothing")
+		return receivedMail{}
+	}
+}
+
+func TestSMTPMailerSend(t *testing.T) {
+	host, port, received := startSMTPServer(t)
+	mailer := &SMTPMailer{Host: host, Port: port, From: "no-reply@planer.local"}
+
+	err := mailer.Send(context.Background(), Message{
+		To:      "user@example.com",
+		Subject: "Подтверждение email",
+		Text:    "Здравствуйте!\nСсылка: http://localhost:3000/verify-email?token=abc\n",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mail := waitMail(t, received)
+	if mail.from != "no-reply@planer.local" {
+		t.Errorf("MAIL FROM = %q", mail.from)
+	}
+	if len(mail.to) != 1 || mail.to[0] != "user@example.com" {
+		t.Errorf("RCPT TO = %v", mail.to)
+	}
+	if mail.auth != "" {
+		t.Errorf("unexpected AUTH without credentials: %q", mail.auth)
+	}
+
+	header, body, _ := strings.Cut(mail.data, "\n\n")
+	reader := textproto.NewReader(bufio.NewReader(strings.NewReader(header + "\n\n")))
+	fields, err := reader.ReadMIMEHeader()
+	if err != nil {
+		t.Fatal(err)
+	}
+	subject, err := new(mime.WordDecoder).DecodeHeader(fields.Get("Subject"))
+	if err != nil || subject != "Подтверждение email" {
+		t.Errorf("Subject = %q (%v)", subject, err)
+	}
+	if fields.Get("To") != "user@example.com" || !strings.HasPrefix(fields.Get("Content-Type"), "text/plain; charset=utf-8") {
+		t.Errorf("unexpected headers: %v", fields)
+	}
+	if !strings.Contains(body, "verify-email?token=abc") {
+		t.Errorf("body = %q", body)
+	}
+}
+
+func TestSMTPMailerAuthenticates(t *testing.T) {
+	host, port, received := startSMTPServer(t)
+	mailer := &SMTPMailer{Host: host, Port: port, Username: "planer", Password: "secret", From: "no-reply@planer.local"}
+
+	if err := mailer.Send(context.Background(), Message{To: "user@example.com", Subject: "Test", Text: "Test"}); err != nil {
+		t.Fatal(err)
+	}
+
+	mail := waitMail(t, received)
+	credentials, err := base64.StdEncoding.DecodeString(mail.auth)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(credentials) != "\x00planer\x00secret" {
+		t.Fatalf("AUTH PLAIN credentials = %q", credentials)
+	}
+}
+
+func TestSMTPMailerReportsUnreachableServer(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := listener.Addr().(*net.TCPAddr).Port
+	listener.Close()
+
+	mailer := &SMTPMailer{Host: "127.0.0.1", Port: port, From: "no-reply@planer.local"}
+	err = mailer.Send(context.Background(), Message{To: "user@example.com", Subject: "Test", Text: "Test"})
+	if err == nil || !strings.Contains(err.Error(), fmt.Sprintf("127.0.0.1:%d", port)) {
+		t.Fatalf("err = %v, want a delivery error naming the server", err)
+	}
+}
+
+func TestFileMailerWritesMessage(t *testing.T) {
+	dir := t.TempDir()
+	mailer := &FileMailer{Dir: dir, From: "no-reply@planer.local"}
+
+	if err := mailer.Send(context.Background(), Message{To: "user@example.com", Subject: "Test", Text: "Привет"}); err != nil {
+		t.Fatal(err)
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "*user_at_example.com.eml"))
+	if err != nil || len(files) != 1 {
+		t.Fatalf("files = %v (%v)", files, err)
+	}
+	data, err := os.ReadFile(files[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), "To: user@example.com\r\n") || !strings.HasSuffix(string(data), "Привет") {
+		t.Fatalf("message = %q", data)
+	}
+}
@@ -2,7 +2,9 @@ package planner
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"log"
@@ -10,8 +12,12 @@ import (
 	"os"
 	"strings"
 	"time"
+
+	"github.com/planer/backend/internal/storage"
 )
 
+const huggingFaceModel = "black-forest-labs/FLUX.1-dev"
+
 type AIPlanner struct {
 	APIToken    string
 	APIEndpoint string
@@ -33,14 +39,11 @@ type AIGenerationResponse struct {
 	Error        string `json:"error,omitempty"`
 }
 
+var ErrAIDisabled = errors.New("AI провайдер не настроен")
+
 func NewAIPlanner() *AIPlanner {
 	apiToken := os.Getenv("HUGGINGFACE_API_TOKEN")
 
-	if apiToken == "" {
-		apiToken = "тут писал токен в коде,но с появлением render больше так не делаю,старый код"
-		log.Println("HUGGINGFACE_API_TOKEN не найден, используем резервный токен")
-	}
-
 	if apiToken == "" {
 		log.Println("HUGGINGFACE_API_TOKEN не найден, используем тестовый режим")
 	}
@@ -54,7 +57,34 @@ func NewAIPlanner() *AIPlanner {
 	}
 }
 
-func (ap *AIPlanner) GeneratePlan(req AIGenerationRequest) (*AIGenerationResponse, error) {
+func (ap *AIPlanner) CheckStatus(ctx context.Context) error {
+	if ap.APIToken == "" {
+		return ErrAIDisabled
+	}
+
+	request, err := http.NewRequestWithContext(ctx, "GET", ap.APIEndpoint+huggingFaceModel, nil)
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Authorization", "Bearer "+ap.APIToken)
+
+	client := &http.Client{Timeout: 5 * time.Second}
+	resp, err := client.Do(request)
+	if err != nil {
+		return fmt.Errorf("AI провайдер недоступен: %v", err)
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden:
+		return fmt.Errorf("AI провайдер отклонил токен, статус: %d", resp.StatusCode)
+	case resp.StatusCode >= 500 && resp.StatusCode != http.StatusServiceUnavailable:
+		return fmt.Errorf("ошибка AI провайдера, статус: %d", resp.StatusCode)
+	}
+	return nil
+}
+
+func (ap *AIPlanner) GeneratePlan(ctx context.Context, req AIGenerationRequest) (*AIGenerationResponse, error) {
 	if ap.APIToken == "" {
 		return ap.mockGeneratePlan(req)
 	}
@@ -76,11 +106,11 @@ func (ap *AIPlanner) GeneratePlan(req AIGenerationRequest) (*AIGenerationRespons
 		return nil, fmt.Errorf("ошибка при формировании запроса: %v", err)
 	}
 
-	modelEndpoint := "black-forest-labs/FLUX.1-dev"
+	modelEndpoint := huggingFaceModel
 
 	log.Printf("Используем модель Hugging Face: %s", modelEndpoint)
 
-	request, err := http.NewRequest("POST", ap.APIEndpoint+modelEndpoint, bytes.NewBuffer(requestBody))
+	request, err := http.NewRequestWithContext(ctx, "POST", ap.APIEndpoint+modelEndpoint, bytes.NewBuffer(requestBody))
 	if err != nil {
 		return nil, fmt.Errorf("ошибка при создании HTTP запроса: %v", err)
 	}
@@ -110,18 +140,10 @@ func (ap *AIPlanner) GeneratePlan(req AIGenerationRequest) (*AIGenerationRespons
 	contentType := resp.Header.Get("Content-Type")
 	log.Printf("Тип контента ответа: %s", contentType)
 
-	timestamp := time.Now().Unix()
-	floorPlanFilename := fmt.Sprintf("floorplan_%d_%d_%d.png", req.Area, req.Rooms, timestamp)
-	render3DFilename := fmt.Sprintf("render3d_%d_%d_%d.png", req.Area, req.Rooms, timestamp)
-
-	staticBasePath := "./static/plans/"
-	os.MkdirAll(staticBasePath, os.ModePerm)
-	floorPlanPath := staticBasePath + floorPlanFilename
-	_ = staticBasePath + render3DFilename
-	floorPlanURL := "/static/plans/" + floorPlanFilename
-	render3DURL := "/static/plans/" + render3DFilename
-
-	err = ioutil.WriteFile(floorPlanPath, body, 0644)
+	if contentType == "" {
+		contentType = "image/png"
+	}
+	floorPlanURL, err := storage.SaveAsset(ctx, "plans", body, contentType)
 	if err != nil {
 		log.Printf("Ошибка при сохранении изображения плана: %v", err)
 	}
@@ -138,7 +160,6 @@ func (ap *AIPlanner) GeneratePlan(req AIGenerationRequest) (*AIGenerationRespons
 	return &AIGenerationResponse{
 		RoomData:     rooms,
 		FloorPlanURL: floorPlanURL,
-		Render3DURL:  render3DURL,
 	}, nil
 }
 
@@ -165,29 +186,43 @@ func (ap *AIPlanner) mockGeneratePlan(req AIGenerationRequest) (*AIGenerationRes
 	}, nil
 }
 
-func (ap *AIPlanner) GenerateInteriorDesign(roomType, style string) (string, error) {
+type InteriorGeneration struct {
+	URL        string
+	Prompt     string
+	Model      string
+	Parameters map[string]interface{}
+}
+
+func (ap *AIPlanner) GenerateInteriorDesign(ctx context.Context, roomType, style string) (*InteriorGeneration, error) {
+	prompt := fmt.Sprintf("Realistic interior design of a %s in %s style, professional photography, detailed, natural lighting, 8k resolution", roomType, style)
+	fallback := &InteriorGeneration{
+		URL:    fmt.Sprintf("https://source.unsplash.com/random/1200x800/?%s,%s,interior", roomType, style),
+		Prompt: prompt,
+		Model:  "unsplash",
+	}
+
 	if ap.APIToken == "" {
-		return fmt.Sprintf("https://source.unsplash.com/random/1200x800/?%s,%s,interior", roomType, style), nil
+		return fallback, nil
 	}
-	prompt := fmt.Sprintf("Realistic interior design of a %s in %s style, professional photography, detailed, natural lighting, 8k resolution", roomType, style)
 
+	parameters := map[string]interface{}{
+		"negative_prompt":     "low quality, blurry, distorted, disfigured, poor design, unrealistic, cartoon",
+		"num_inference_steps": 40,
+		"guidance_scale":      8.0,
+	}
 	requestBody, err := json.Marshal(map[string]interface{}{
-		"inputs": prompt,
-		"parameters": map[string]interface{}{
-			"negative_prompt":     "low quality, blurry, distorted, disfigured, poor design, unrealistic, cartoon",
-			"num_inference_steps": 40,
-			"guidance_scale":      8.0,
-		},
+		"inputs":     prompt,
+		"parameters": parameters,
 	})
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
-	modelEndpoint := "black-forest-labs/FLUX.1-dev"
+	modelEndpoint := huggingFaceModel
 
-	request, err := http.NewRequest("POST", ap.APIEndpoint+modelEndpoint, bytes.NewBuffer(requestBody))
+	request, err := http.NewRequestWithContext(ctx, "POST", ap.APIEndpoint+modelEndpoint, bytes.NewBuffer(requestBody))
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
 	request.Header.Set("Authorization", "Bearer "+ap.APIToken)
@@ -195,33 +230,33 @@ func (ap *AIPlanner) GenerateInteriorDesign(roomType, style string) (string, err
 
 	response, err := ap.HTTPClient.Do(request)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 	defer response.Body.Close()
 
 	if response.StatusCode != http.StatusOK {
 		bodyText, _ := ioutil.ReadAll(response.Body)
-		return "", fmt.Errorf("API error: %s, status: %d", string(bodyText), response.StatusCode)
+		return nil, fmt.Errorf("API error: %s, status: %d", string(bodyText), response.StatusCode)
 	}
-	timestamp := time.Now().Unix()
-	interiorFilename := fmt.Sprintf("interior_%s_%s_%d.png", roomType, style, timestamp)
-
-	staticBasePath := "./static/interiors/"
-	os.MkdirAll(staticBasePath, os.ModePerm)
-	interiorPath := staticBasePath + interiorFilename
-
-	interiorURL := "/static/interiors/" + interiorFilename
-
 	body, err := ioutil.ReadAll(response.Body)
 	if err != nil {
-		return "", fmt.Errorf("ошибка при чтении ответа: %v", err)
+		return nil, fmt.Errorf("ошибка при чтении ответа: %v", err)
 	}
 
-	err = ioutil.WriteFile(interiorPath, body, 0644)
+	contentType := response.Header.Get("Content-Type")
+	if contentType == "" {
+		contentType = "image/png"
+	}
+	interiorURL, err := storage.SaveAsset(ctx, "interiors", body, contentType)
 	if err != nil {
 		log.Printf("Ошибка при сохранении изображения интерьера: %v", err)
-		return fmt.Sprintf("https://source.unsplash.com/random/1200x800/?%s,%s,interior", roomType, style), nil
+		return fallback, nil
 	}
 
-	return interiorURL, nil
+	return &InteriorGeneration{
+		URL:        interiorURL,
+		Prompt:     prompt,
+		Model:      modelEndpoint,
+		Parameters: parameters,
+	}, nil
 }
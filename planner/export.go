@@ -0,0 +1,128 @@
+package planner
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"strconv"
+	"time"
+
+	"github.com/planer/backend/internal/database"
+)
+
+type PlanExport struct {
+	Plan      PlanResponse   `json:"plan"`
+	Revisions []PlanRevision `json:"revisions"`
+}
+
+func ExportUserPlans(ctx context.Context, userID int) ([]PlanExport, error) {
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
+
+	rows, err := database.DB.QueryContext(ctx,
+		"SELECT "+planColumns+", p.deleted_at FROM apartment_plans p WHERE p.user_id = $1 ORDER BY p.id",
+		userID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	exports := make([]PlanExport, 0)
+	ids := make([]int, 0)
+	for rows.Next() {
+		var deletedAt sql.NullTime
+		plan, id, err := scanPlan(rows, &deletedAt)
+		if err != nil {
+			return nil, err
+		}
+		if deletedAt.Valid {
+			plan.DeletedAt = deletedAt.Time.Format(time.RFC3339)
+		}
+		exports = append(exports, PlanExport{Plan: plan})
+		ids = append(ids, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	for i, id := range ids {
+		if err := loadPlanDetails(ctx, &exports[i].Plan, id); err != nil {
+			return nil, err
+		}
+
+		var err error
+		exports[i].Revisions, err = loadRevisionDocuments(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return exports, nil
+}
+
+func loadRevisionDocuments(ctx context.Context, planID int) ([]PlanRevision, error) {
+	rows, err := database.DB.QueryContext(ctx,
+		"SELECT id, revision, author_id, summary, document, created_at FROM plan_revisions WHERE plan_id = $1 ORDER BY revision",
+		planID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	revisions := make([]PlanRevision, 0)
+	for rows.Next() {
+		var rev PlanRevision
+		var authorID sql.NullInt64
+		var document string
+		var createdAt time.Time
+		if err := rows.Scan(&rev.ID, &rev.Revision, &authorID, &rev.Summary, &document, &createdAt); err != nil {
+			return nil, err
+		}
+
+		var plan PlanResponse
+		if err := json.Unmarshal([]byte(document), &plan); err != nil {
+			return nil, err
+		}
+
+		rev.PlanID = strconv.Itoa(planID)
+		rev.AuthorID = int(authorID.Int64)
+		rev.CreatedAt = createdAt.Format(time.RFC3339)
+		rev.Plan = &plan
+		revisions = append(revisions, rev)
+	}
+	return revisions, rows.Err()
+}
+
+func PurgeUserPlans(ctx context.Context, userID int) error {
+	rows, err := database.DB.QueryContext(ctx, "SELECT id FROM apartment_plans WHERE user_id = $1", userID)
+	if err != nil {
+		return err
+	}
+
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return err
+		}
+		ids = append(ids, id)
+	}
+	if err := rows.Err(); err != nil {
+		rows.Close()
+		return err
+	}
+	rows.Close()
+
+	for _, id := range ids {
+		if err := purgePlan(ctx, id); err != nil {
+			return err
+		}
+	}
+
+	_, err = database.DB.ExecContext(ctx, "DELETE FROM plan_folders WHERE user_id = $1", userID)
+	return err
+}
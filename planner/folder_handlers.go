@@ -0,0 +1,296 @@
+package planner
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/planer/backend/internal/database"
+)
+
+type FolderRequest struct {
+	Name     string `json:"name"`
+	ParentID int    `json:"parent_id"`
+}
+
+type PlanTagsRequest struct {
+	Tags []string `json:"tags"`
+}
+
+type PlanFolderRequest struct {
+	FolderID int `json:"folder_id"`
+}
+
+type PlanFavoriteRequest struct {
+	Favorite bool `json:"favorite"`
+}
+
+func ListFoldersHandler(c *gin.Context) {
+	if database.MockMode {
+		c.JSON(http.StatusOK, []PlanFolder{})
+		return
+	}
+
+	userID, ok := requireUserID(c)
+	if !ok {
+		return
+	}
+
+	folders, err := listFolders(c.Request.Context(), userID)
+	if err != nil {
+		log.Printf("Ошибка при получении папок: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Не удалось получить папки"})
+		return
+	}
+
+	c.JSON(http.StatusOK, folders)
+}
+
+func CreateFolderHandler(c *gin.Context) {
+	if !requireDatabase(c) {
+		return
+	}
+
+	userID, ok := requireUserID(c)
+	if !ok {
+		return
+	}
+
+	req, ok := bindFolderRequest(c, userID)
+	if !ok {
+		return
+	}
+
+	folder, err := createFolder(c.Request.Context(), userID, req.ParentID, req.Name)
+	if err != nil {
+		log.Printf("Ошибка при создании папки: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Не удалось создать папку"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, folder)
+}
+
+func UpdateFolderHandler(c *gin.Context) {
+	if !requireDatabase(c) {
+		return
+	}
+
+	userID, ok := requireUserID(c)
+	if !ok {
+		return
+	}
+	folderID, ok := intParam(c, "id")
+	if !ok {
+		return
+	}
+
+	req, ok := bindFolderRequest(c, userID)
+	if !ok {
+		return
+	}
+
+	folder, err := updateFolder(c.Request.Context(), userID, folderID, req.ParentID, req.Name)
+	if errors.Is(err, errFolderCycle) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Папка не найдена"})
+		return
+	}
+	if err != nil {
+		log.Printf("Ошибка при обновлении папки: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Не удалось обновить папку"})
+		return
+	}
+
+	c.JSON(http.StatusOK, folder)
+}
+
+func DeleteFolderHandler(c *gin.Context) {
+	if !requireDatabase(c) {
+		return
+	}
+
+	userID, ok := requireUserID(c)
+	if !ok {
+		return
+	}
+	folderID, ok := intParam(c, "id")
+	if !ok {
+		return
+	}
+
+	deleted, err := deleteFolder(c.Request.Context(), userID, folderID)
+	if err != nil {
+		log.Printf("Ошибка при удалении папки: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Не удалось удалить папку"})
+		return
+	}
+	if !deleted {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Папка не найдена"})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func bindFolderRequest(c *gin.Context, userID int) (FolderRequest, bool) {
+	var req FolderRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Неверный формат запроса"})
+		return req, false
+	}
+
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" || len([]rune(req.Name)) > maxFolderName {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Название папки должно быть от 1 до 100 символов"})
+		return req, false
+	}
+
+	return req, requireOwnedFolder(c, userID, req.ParentID)
+}
+
+func requireOwnedFolder(c *gin.Context, userID, folderID int) bool {
+	if folderID <= 0 {
+		return true
+	}
+
+	owner, err := ownsFolder(c.Request.Context(), userID, folderID)
+	if err != nil {
+		log.Printf("Ошибка при проверке папки: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Не удалось получить папку"})
+		return false
+	}
+	if !owner {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Папка не найдена"})
+		return false
+	}
+	return true
+}
+
+func ListTagsHandler(c *gin.Context) {
+	if database.MockMode {
+		c.JSON(http.StatusOK, []PlanTag{})
+		return
+	}
+
+	userID, ok := requireUserID(c)
+	if !ok {
+		return
+	}
+
+	tags, err := listUserTags(c.Request.Context(), userID)
+	if err != nil {
+		log.Printf("Ошибка при получении тегов: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Не удалось получить теги"})
+		return
+	}
+
+	c.JSON(http.StatusOK, tags)
+}
+
+func SetPlanTagsHandler(c *gin.Context) {
+	if !requireDatabase(c) {
+		return
+	}
+
+	userID, ok := requireUserID(c)
+	if !ok {
+		return
+	}
+	planID, ok := intParam(c, "id")
+	if !ok {
+		return
+	}
+
+	var req PlanTagsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Неверный формат запроса"})
+		return
+	}
+	tags, err := normalizeTags(req.Tags)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	updated, err := setPlanTags(c.Request.Context(), userID, planID, tags)
+	respondPlanUpdate(c, userID, planID, updated, err)
+}
+
+func SetPlanFolderHandler(c *gin.Context) {
+	if !requireDatabase(c) {
+		return
+	}
+
+	userID, ok := requireUserID(c)
+	if !ok {
+		return
+	}
+	planID, ok := intParam(c, "id")
+	if !ok {
+		return
+	}
+
+	var req PlanFolderRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Неверный формат запроса"})
+		return
+	}
+	if !requireOwnedFolder(c, userID, req.FolderID) {
+		return
+	}
+
+	updated, err := setPlanFolder(c.Request.Context(), userID, planID, req.FolderID)
+	respondPlanUpdate(c, userID, planID, updated, err)
+}
+
+func SetPlanFavoriteHandler(c *gin.Context) {
+	if !requireDatabase(c) {
+		return
+	}
+
+	userID, ok := requireUserID(c)
+	if !ok {
+		return
+	}
+	planID, ok := intParam(c, "id")
+	if !ok {
+		return
+	}
+
+	var req PlanFavoriteRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Неверный формат запроса"})
+		return
+	}
+
+	updated, err := setPlanFavorite(c.Request.Context(), userID, planID, req.Favorite)
+	respondPlanUpdate(c, userID, planID, updated, err)
+}
+
+func respondPlanUpdate(c *gin.Context, userID, planID int, updated bool, err error) {
+	if err != nil {
+		log.Printf("Ошибка при обновлении плана: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Не удалось обновить план"})
+		return
+	}
+	if !updated {
+		c.JSON(http.StatusNotFound, gin.H{"error": "План не найден"})
+		return
+	}
+
+	plan, err := getUserPlan(c.Request.Context(), userID, planID)
+	if err != nil {
+		log.Printf("Ошибка при получении плана: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Не удалось получить план"})
+		return
+	}
+
+	c.JSON(http.StatusOK, plan)
+}
@@ -0,0 +1,304 @@
+package planner
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/planer/backend/internal/database"
+)
+
+const (
+	maxPlanTags   = 20
+	maxTagLength  = 50
+	maxFolderName = 100
+)
+
+var errFolderCycle = errors.New("папку нельзя переместить внутрь самой себя")
+
+type PlanFolder struct {
+	ID        int    `json:"id"`
+	ParentID  int    `json:"parent_id,omitempty"`
+	Name      string `json:"name"`
+	CreatedAt string `json:"created_at"`
+}
+
+type PlanTag struct {
+	Tag   string `json:"tag"`
+	Plans int    `json:"plans"`
+}
+
+func normalizeTags(tags []string) ([]string, error) {
+	seen := make(map[string]bool)
+	result := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag == "" || seen[tag] {
+			continue
+		}
+		if len([]rune(tag)) > maxTagLength {
+			return nil, fmt.Errorf("тег длиннее %d символов: %s", maxTagLength, tag)
+		}
+		seen[tag] = true
+		result = append(result, tag)
+	}
+	if len(result) > maxPlanTags {
+		return nil, fmt.Errorf("у плана может быть не больше %d тегов", maxPlanTags)
+	}
+	sort.Strings(result)
+	return result, nil
+}
+
+func scanFolder(row rowScanner) (PlanFolder, error) {
+	var folder PlanFolder
+	var parentID sql.NullInt64
+	var createdAt time.Time
+	if err := row.Scan(&folder.ID, &parentID, &folder.Name, &createdAt); err != nil {
+		return folder, err
+	}
+	folder.ParentID = int(parentID.Int64)
+	folder.CreatedAt = createdAt.Format(time.RFC3339)
+	return folder, nil
+}
+
+func listFolders(ctx context.Context, userID int) ([]PlanFolder, error) {
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
+
+	rows, err := database.DB.QueryContext(ctx,
+		"SELECT id, parent_id, name, created_at FROM plan_folders WHERE user_id = $1 ORDER BY name, id",
+		userID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	folders := make([]PlanFolder, 0)
+	for rows.Next() {
+		folder, err := scanFolder(rows)
+		if err != nil {
+			return nil, err
+		}
+		folders = append(folders, folder)
+	}
+	return folders, rows.Err()
+}
+
+func ownsFolder(ctx context.Context, userID, folderID int) (bool, error) {
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
+
+	var exists int
+	err := database.DB.QueryRowContext(ctx,
+		"SELECT 1 FROM plan_folders WHERE id = $1 AND user_id = $2",
+		folderID, userID,
+	).Scan(&exists)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func createFolder(ctx context.Context, userID, parentID int, name string) (*PlanFolder, error) {
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
+
+	row := database.DB.QueryRowContext(ctx,
+		"INSERT INTO plan_folders (user_id, parent_id, name) VALUES ($1, $2, $3) RETURNING id, parent_id, name, created_at",
+		userID, nullableID(parentID), name,
+	)
+	folder, err := scanFolder(row)
+	if err != nil {
+		return nil, err
+	}
+	return &folder, nil
+}
+
+func updateFolder(ctx context.Context, userID, folderID, parentID int, name string) (*PlanFolder, error) {
+	folders, err := listFolders(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if parentID > 0 {
+		for _, id := range folderSubtree(folders, folderID) {
+			if id == parentID {
+				return nil, errFolderCycle
+			}
+		}
+	}
+
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
+
+	row := database.DB.QueryRowContext(ctx,
+		"UPDATE plan_folders SET parent_id = $1, name = $2 WHERE id = $3 AND user_id = $4 RETURNING id, parent_id, name, created_at",
+		nullableID(parentID), name, folderID, userID,
+	)
+	folder, err := scanFolder(row)
+	if err != nil {
+		return nil, err
+	}
+	return &folder, nil
+}
+
+func deleteFolder(ctx context.Context, userID, folderID int) (bool, error) {
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
+
+	result, err := database.DB.ExecContext(ctx,
+		"DELETE FROM plan_folders WHERE id = $1 AND user_id = $2",
+		folderID, userID,
+	)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
+
+func folderSubtree(folders []PlanFolder, rootID int) []int {
+	children := make(map[int][]int)
+	for _, folder := range folders {
+		children[folder.ParentID] = append(children[folder.ParentID], folder.ID)
+	}
+
+	ids := []int{rootID}
+	for i := 0; i < len(ids); i++ {
+		ids = append(ids, children[ids[i]]...)
+	}
+	return ids
+}
+
+func filterFolderIDs(ctx context.Context, userID int, filter PlanFilter) ([]int, error) {
+	if filter.FolderID <= 0 {
+		return nil, nil
+	}
+	if !filter.Recursive {
+		return []int{filter.FolderID}, nil
+	}
+
+	folders, err := listFolders(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	return folderSubtree(folders, filter.FolderID), nil
+}
+
+func setPlanFolder(ctx context.Context, userID, planID, folderID int) (bool, error) {
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
+
+	result, err := database.DB.ExecContext(ctx,
+		"UPDATE apartment_plans SET folder_id = $1 WHERE id = $2 AND user_id = $3 AND deleted_at IS NULL",
+		nullableID(folderID), planID, userID,
+	)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
+
+func setPlanFavorite(ctx context.Context, userID, planID int, favorite bool) (bool, error) {
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
+
+	result, err := database.DB.ExecContext(ctx,
+		"UPDATE apartment_plans SET is_favorite = $1 WHERE id = $2 AND user_id = $3 AND deleted_at IS NULL",
+		favorite, planID, userID,
+	)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
+
+func setPlanTags(ctx context.Context, userID, planID int, tags []string) (bool, error) {
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
+
+	tx, err := database.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	var exists int
+	err = tx.QueryRowContext(ctx,
+		"SELECT 1 FROM apartment_plans WHERE id = $1 AND user_id = $2 AND deleted_at IS NULL",
+		planID, userID,
+	).Scan(&exists)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM plan_tags WHERE plan_id = $1", planID); err != nil {
+		return false, err
+	}
+	for _, tag := range tags {
+		if _, err := tx.ExecContext(ctx, "INSERT INTO plan_tags (plan_id, tag) VALUES ($1, $2)", planID, tag); err != nil {
+			return false, err
+		}
+	}
+
+	return true, tx.Commit()
+}
+
+func loadPlanTags(ctx context.Context, planID int) ([]string, error) {
+	rows, err := database.DB.QueryContext(ctx,
+		"SELECT tag FROM plan_tags WHERE plan_id = $1 ORDER BY tag",
+		planID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tags := make([]string, 0)
+	for rows.Next() {
+		var tag string
+		if err := rows.Scan(&tag); err != nil {
+			return nil, err
+		}
+		tags = append(tags, tag)
+	}
+	return tags, rows.Err()
+}
+
+func listUserTags(ctx context.Context, userID int) ([]PlanTag, error) {
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
+
+	rows, err := database.DB.QueryContext(ctx,
+		`SELECT t.tag, COUNT(*)
+		FROM plan_tags t
+		JOIN apartment_plans p ON p.id = t.plan_id
+		WHERE p.user_id = $1 AND p.deleted_at IS NULL
+		GROUP BY t.tag
+		ORDER BY t.tag`,
+		userID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tags := make([]PlanTag, 0)
+	for rows.Next() {
+		var tag PlanTag
+		if err := rows.Scan(&tag.Tag, &tag.Plans); err != nil {
+			return nil, err
+		}
+		tags = append(tags, tag)
+	}
+	return tags, rows.Err()
+}
@@ -1,15 +1,21 @@
 package planner
 
 import (
+	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"math/rand"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
+	"github.com/planer/backend/internal/audit"
+	"github.com/planer/backend/internal/database"
 )
 
 func generateFloorPlanURL(seed int64, style string, rooms int, area int) string {
@@ -77,7 +83,14 @@ func GeneratePlanHandler(c *gin.Context) {
 			Features: req.Features,
 		}
 
-		aiResp, err := aiPlanner.GeneratePlan(aiReq)
+		aiResp, err := aiPlanner.GeneratePlan(c.Request.Context(), aiReq)
+		audit.Record(c, audit.Entry{Action: audit.ActionAIPlan, Details: map[string]interface{}{
+			"model":   huggingFaceModel,
+			"area":    req.Area,
+			"rooms":   req.Rooms,
+			"style":   req.Style,
+			"success": err == nil,
+		}})
 		if err == nil && aiResp != nil {
 			plans := generatePlansFromAI(aiResp, req)
 			log.Printf("Успешно сгенерированы планы с использованием AI: %d планов", len(plans))
@@ -336,14 +349,187 @@ func SavePlanHandler(c *gin.Context) {
 		return
 	}
 
-	plan.ID = uuid.New().String()
-	now := time.Now().Format(time.RFC3339)
-	plan.CreatedAt = now
-	plan.UpdatedAt = now
+	rooms, err := parseRoomData(plan.RoomData)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Некорректные данные комнат"})
+		return
+	}
+
+	if database.MockMode {
+		plan.ID = uuid.New().String()
+		now := time.Now().Format(time.RFC3339)
+		plan.CreatedAt = now
+		plan.UpdatedAt = now
+
+		c.JSON(http.StatusOK, plan)
+		return
+	}
+
+	userID, ok := requireUserID(c)
+	if !ok {
+		return
+	}
+
+	if err := savePlan(c.Request.Context(), userID, &plan, rooms); err != nil {
+		log.Printf("Ошибка при сохранении плана: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Не удалось сохранить план"})
+		return
+	}
+
+	audit.Record(c, audit.Entry{Action: audit.ActionPlanCreate, TargetType: audit.TargetPlan, TargetID: plan.ID})
 
 	c.JSON(http.StatusOK, plan)
 }
 
 func GetUserPlansHandler(c *gin.Context) {
-	c.JSON(http.StatusOK, []PlanResponse{})
+	if database.MockMode {
+		c.JSON(http.StatusOK, []PlanResponse{})
+		return
+	}
+
+	userID, ok := requireUserID(c)
+	if !ok {
+		return
+	}
+
+	filter, err := parsePlanFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Некорректные параметры фильтра: " + err.Error()})
+		return
+	}
+
+	plans, err := listUserPlans(c.Request.Context(), userID, filter)
+	if err != nil {
+		log.Printf("Ошибка при получении планов пользователя: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Не удалось получить планы"})
+		return
+	}
+
+	c.JSON(http.StatusOK, plans)
+}
+
+type UpdatePlanRequest struct {
+	PlanResponse
+	Summary string `json:"summary"`
+}
+
+func UpdatePlanHandler(c *gin.Context) {
+	if !requireDatabase(c) {
+		return
+	}
+
+	userID, ok := requireUserID(c)
+	if !ok {
+		return
+	}
+	planID, ok := intParam(c, "id")
+	if !ok {
+		return
+	}
+
+	var req UpdatePlanRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Неверный формат запроса"})
+		return
+	}
+
+	rooms, err := parseRoomData(req.RoomData)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Некорректные данные комнат"})
+		return
+	}
+
+	plan := req.PlanResponse
+	if req.Summary == "" {
+		req.Summary = "Изменение плана"
+	}
+
+	err = updatePlan(c.Request.Context(), userID, planID, &plan, rooms, req.Summary)
+	if errors.Is(err, sql.ErrNoRows) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "План не найден"})
+		return
+	}
+	if err != nil {
+		log.Printf("Ошибка при обновлении плана: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Не удалось обновить план"})
+		return
+	}
+
+	audit.Record(c, audit.Entry{Action: audit.ActionPlanUpdate, TargetType: audit.TargetPlan, TargetID: planID,
+		Details: map[string]interface{}{"summary": req.Summary}})
+
+	c.JSON(http.StatusOK, plan)
+}
+
+func parsePlanFilter(c *gin.Context) (PlanFilter, error) {
+	filter := PlanFilter{
+		Style:     c.Query("style"),
+		RoomName:  c.Query("room"),
+		Favorite:  c.Query("favorite") == "true",
+		Recursive: c.Query("recursive") == "true",
+		Query:     strings.TrimSpace(c.Query("q")),
+	}
+
+	var err error
+	if filter.Tags, err = normalizeTags(c.QueryArray("tag")); err != nil {
+		return filter, fmt.Errorf("tag: %v", err)
+	}
+
+	ints := map[string]*int{
+		"rooms":     &filter.Rooms,
+		"folder_id": &filter.FolderID,
+	}
+	for key, target := range ints {
+		value := c.Query(key)
+		if value == "" {
+			continue
+		}
+		if *target, err = strconv.Atoi(value); err != nil {
+			return filter, fmt.Errorf("%s: %v", key, err)
+		}
+	}
+
+	floats := map[string]*float64{
+		"min_area":      &filter.MinArea,
+		"max_area":      &filter.MaxArea,
+		"min_room_area": &filter.MinRoomArea,
+		"max_room_area": &filter.MaxRoomArea,
+	}
+	for key, target := range floats {
+		value := c.Query(key)
+		if value == "" {
+			continue
+		}
+		if *target, err = strconv.ParseFloat(value, 64); err != nil {
+			return filter, fmt.Errorf("%s: %v", key, err)
+		}
+	}
+
+	return filter, nil
+}
+
+func requireDatabase(c *gin.Context) bool {
+	if database.MockMode {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "База данных недоступна"})
+		return false
+	}
+	return true
+}
+
+func requireUserID(c *gin.Context) (int, bool) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Требуется авторизация"})
+		return 0, false
+	}
+	return userID.(int), true
+}
+
+func intParam(c *gin.Context, name string) (int, bool) {
+	value, err := strconv.Atoi(c.Param(name))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Некорректный идентификатор"})
+		return 0, false
+	}
+	return value, true
 }
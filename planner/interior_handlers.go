@@ -1,19 +1,32 @@
 package planner
 
 import (
+	"database/sql"
+	"errors"
 	"log"
 	"net/http"
+	"strconv"
 
 	"github.com/gin-gonic/gin"
+	"github.com/planer/backend/internal/audit"
+	"github.com/planer/backend/internal/database"
+	"github.com/planer/backend/internal/projects"
 )
 
 type InteriorDesignRequest struct {
-	RoomType string `json:"room_type" binding:"required"`
-	Style    string `json:"style" binding:"required"`
+	RoomType  string `json:"room_type" binding:"required"`
+	Style     string `json:"style" binding:"required"`
+	ProjectID int    `json:"project_id"`
+	PlanID    int    `json:"plan_id"`
+	RoomName  string `json:"room_name"`
 }
 
 type InteriorDesignResponse struct {
-	URL string `json:"url"`
+	ID        int    `json:"id,omitempty"`
+	ProjectID int    `json:"project_id,omitempty"`
+	PlanID    int    `json:"plan_id,omitempty"`
+	RoomName  string `json:"room_name,omitempty"`
+	URL       string `json:"url"`
 }
 
 func GenerateInteriorHandler(c *gin.Context) {
@@ -25,16 +38,250 @@ func GenerateInteriorHandler(c *gin.Context) {
 
 	log.Printf("Получен запрос на генерацию интерьера: %+v", req)
 
+	userID, authenticated := c.Get("userID")
+	if (req.ProjectID > 0 || req.PlanID > 0) && !database.MockMode {
+		if !authenticated {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Требуется авторизация"})
+			return
+		}
+		if !validateInteriorLinks(c, userID.(int), req) {
+			return
+		}
+	}
+
 	aiPlanner := NewAIPlanner()
 
-	url, err := aiPlanner.GenerateInteriorDesign(req.RoomType, req.Style)
+	generation, err := aiPlanner.GenerateInteriorDesign(c.Request.Context(), req.RoomType, req.Style)
 	if err != nil {
 		log.Printf("Ошибка при генерации интерьера: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Не удалось сгенерировать дизайн интерьера"})
 		return
 	}
 
-	c.JSON(http.StatusOK, InteriorDesignResponse{
-		URL: url,
-	})
+	resp := InteriorDesignResponse{
+		URL: generation.URL,
+	}
+
+	if authenticated && !database.MockMode {
+		design := InteriorDesign{
+			ProjectID:  req.ProjectID,
+			PlanID:     req.PlanID,
+			RoomName:   req.RoomName,
+			RoomType:   req.RoomType,
+			Style:      req.Style,
+			Prompt:     generation.Prompt,
+			Model:      generation.Model,
+			Parameters: generation.Parameters,
+			URL:        generation.URL,
+		}
+		if err := saveInteriorDesign(c.Request.Context(), userID.(int), &design); err != nil {
+			log.Printf("Ошибка при сохранении дизайна интерьера: %v", err)
+		} else {
+			resp.ID = design.ID
+			resp.ProjectID = design.ProjectID
+			resp.PlanID = design.PlanID
+			resp.RoomName = design.RoomName
+		}
+	}
+
+	entry := audit.Entry{Action: audit.ActionAIInterior, Details: map[string]interface{}{
+		"model":     generation.Model,
+		"room_type": req.RoomType,
+		"style":     req.Style,
+	}}
+	if resp.ID > 0 {
+		entry.TargetType = audit.TargetInterior
+		entry.TargetID = resp.ID
+	}
+	audit.Record(c, entry)
+
+	c.JSON(http.StatusOK, resp)
+}
+
+func validateInteriorLinks(c *gin.Context, userID int, req InteriorDesignRequest) bool {
+	ctx := c.Request.Context()
+
+	if req.ProjectID > 0 {
+		owner, err := projects.CanManage(ctx, req.ProjectID, userID)
+		if err != nil {
+			log.Printf("Ошибка при проверке проекта: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Не удалось получить проект"})
+			return false
+		}
+		if !owner {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Проект не найден"})
+			return false
+		}
+	}
+
+	if req.PlanID > 0 {
+		plan, err := getUserPlan(ctx, userID, req.PlanID)
+		if errors.Is(err, sql.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "План не найден"})
+			return false
+		}
+		if err != nil {
+			log.Printf("Ошибка при получении плана: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Не удалось получить план"})
+			return false
+		}
+
+		if req.RoomName != "" {
+			rooms, _ := parseRoomData(plan.RoomData)
+			found := false
+			for _, room := range rooms {
+				if room.Name == req.RoomName {
+					found = true
+					break
+				}
+			}
+			if !found {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Комната не найдена в плане"})
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+func ListInteriorsHandler(c *gin.Context) {
+	if database.MockMode {
+		c.JSON(http.StatusOK, []InteriorDesign{})
+		return
+	}
+
+	userID, ok := requireUserID(c)
+	if !ok {
+		return
+	}
+
+	filter := InteriorFilter{
+		RoomType: c.Query("room_type"),
+		Style:    c.Query("style"),
+		RoomName: c.Query("room"),
+	}
+	filter.PlanID, _ = strconv.Atoi(c.Query("plan_id"))
+	filter.ProjectID, _ = strconv.Atoi(c.Query("project_id"))
+
+	designs, err := ListUserInteriors(c.Request.Context(), userID, filter)
+	if err != nil {
+		log.Printf("Ошибка при получении истории интерьеров: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Не удалось получить дизайны интерьеров"})
+		return
+	}
+
+	c.JSON(http.StatusOK, designs)
+}
+
+func GetInteriorHandler(c *gin.Context) {
+	if !requireDatabase(c) {
+		return
+	}
+
+	userID, ok := requireUserID(c)
+	if !ok {
+		return
+	}
+	interiorID, ok := intParam(c, "id")
+	if !ok {
+		return
+	}
+
+	design, err := getUserInterior(c.Request.Context(), userID, interiorID)
+	if errors.Is(err, sql.ErrNoRows) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Дизайн интерьера не найден"})
+		return
+	}
+	if err != nil {
+		log.Printf("Ошибка при получении дизайна интерьера: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Не удалось получить дизайн интерьера"})
+		return
+	}
+
+	c.JSON(http.StatusOK, design)
+}
+
+func DeleteInteriorHandler(c *gin.Context) {
+	if !requireDatabase(c) {
+		return
+	}
+
+	userID, ok := requireUserID(c)
+	if !ok {
+		return
+	}
+	interiorID, ok := intParam(c, "id")
+	if !ok {
+		return
+	}
+
+	deleted, err := softDeleteInterior(c.Request.Context(), userID, interiorID)
+	if err != nil {
+		log.Printf("Ошибка при удалении дизайна интерьера: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Не удалось удалить дизайн интерьера"})
+		return
+	}
+	if !deleted {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Дизайн интерьера не найден"})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func ListInteriorTrashHandler(c *gin.Context) {
+	if database.MockMode {
+		c.JSON(http.StatusOK, []InteriorDesign{})
+		return
+	}
+
+	userID, ok := requireUserID(c)
+	if !ok {
+		return
+	}
+
+	designs, err := ListUserInteriors(c.Request.Context(), userID, InteriorFilter{Deleted: true})
+	if err != nil {
+		log.Printf("Ошибка при получении корзины интерьеров: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Не удалось получить корзину"})
+		return
+	}
+
+	c.JSON(http.StatusOK, designs)
+}
+
+func RestoreInteriorHandler(c *gin.Context) {
+	if !requireDatabase(c) {
+		return
+	}
+
+	userID, ok := requireUserID(c)
+	if !ok {
+		return
+	}
+	interiorID, ok := intParam(c, "id")
+	if !ok {
+		return
+	}
+
+	restored, err := restoreDeletedInterior(c.Request.Context(), userID, interiorID)
+	if err != nil {
+		log.Printf("Ошибка при восстановлении дизайна интерьера: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Не удалось восстановить дизайн интерьера"})
+		return
+	}
+	if !restored {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Дизайн интерьера не найден в корзине"})
+		return
+	}
+
+	design, err := getUserInterior(c.Request.Context(), userID, interiorID)
+	if err != nil {
+		log.Printf("Ошибка при получении дизайна интерьера: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Не удалось получить дизайн интерьера"})
+		return
+	}
+
+	c.JSON(http.StatusOK, design)
 }
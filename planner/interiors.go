@@ -0,0 +1,243 @@
+package planner
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/planer/backend/internal/database"
+	"github.com/planer/backend/internal/storage"
+)
+
+type InteriorDesign struct {
+	ID         int                    `json:"id"`
+	ProjectID  int                    `json:"project_id,omitempty"`
+	PlanID     int                    `json:"plan_id,omitempty"`
+	RoomName   string                 `json:"room_name,omitempty"`
+	RoomType   string                 `json:"room_type"`
+	Style      string                 `json:"style"`
+	Prompt     string                 `json:"prompt"`
+	Model      string                 `json:"model"`
+	Parameters map[string]interface{} `json:"parameters,omitempty"`
+	URL        string                 `json:"url"`
+	CreatedAt  time.Time              `json:"created_at"`
+	DeletedAt  *time.Time             `json:"deleted_at,omitempty"`
+}
+
+type InteriorFilter struct {
+	RoomType       string
+	Style          string
+	PlanID         int
+	RoomName       string
+	ProjectID      int
+	Deleted        bool
+	IncludeDeleted bool
+}
+
+const interiorColumns = `id, project_id, plan_id, room_name, room_type, style, prompt, model, parameters, image_url, created_at, deleted_at`
+
+func scanInterior(row rowScanner) (InteriorDesign, error) {
+	var design InteriorDesign
+	var projectID, planID sql.NullInt64
+	var parameters sql.NullString
+	var deletedAt sql.NullTime
+	err := row.Scan(&design.ID, &projectID, &planID, &design.RoomName, &design.RoomType, &design.Style,
+		&design.Prompt, &design.Model, &parameters, &design.URL, &design.CreatedAt, &deletedAt)
+	if err != nil {
+		return design, err
+	}
+
+	design.ProjectID = int(projectID.Int64)
+	design.PlanID = int(planID.Int64)
+	if parameters.Valid && parameters.String != "" {
+		if err := json.Unmarshal([]byte(parameters.String), &design.Parameters); err != nil {
+			return design, err
+		}
+	}
+	if deletedAt.Valid {
+		design.DeletedAt = &deletedAt.Time
+	}
+	return design, nil
+}
+
+func nullableID(id int) interface{} {
+	if id > 0 {
+		return id
+	}
+	return nil
+}
+
+func saveInteriorDesign(ctx context.Context, userID int, design *InteriorDesign) error {
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
+
+	var parameters interface{}
+	if design.Parameters != nil {
+		data, err := json.Marshal(design.Parameters)
+		if err != nil {
+			return err
+		}
+		parameters = string(data)
+	}
+
+	tx, err := database.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRowContext(ctx,
+		`INSERT INTO interior_designs (user_id, project_id, plan_id, room_name, room_type, style, prompt, model, parameters, image_url)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		RETURNING id, created_at`,
+		userID, nullableID(design.ProjectID), nullableID(design.PlanID), design.RoomName, design.RoomType, design.Style,
+		design.Prompt, design.Model, parameters, design.URL,
+	).Scan(&design.ID, &design.CreatedAt)
+	if err != nil {
+		return err
+	}
+	if err := storage.RetainURLs(ctx, tx, design.URL); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func ListUserInteriors(ctx context.Context, userID int, filter InteriorFilter) ([]InteriorDesign, error) {
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
+
+	conditions := []string{"user_id = $1"}
+	args := []interface{}{userID}
+
+	addCondition := func(format string, value interface{}) {
+		args = append(args, value)
+		conditions = append(conditions, fmt.Sprintf(format, len(args)))
+	}
+
+	switch {
+	case filter.Deleted:
+		conditions = append(conditions, "deleted_at IS NOT NULL")
+	case !filter.IncludeDeleted:
+		conditions = append(conditions, "deleted_at IS NULL")
+	}
+	if filter.RoomType != "" {
+		addCondition("room_type = $%d", filter.RoomType)
+	}
+	if filter.Style != "" {
+		addCondition("style = $%d", filter.Style)
+	}
+	if filter.PlanID > 0 {
+		addCondition("plan_id = $%d", filter.PlanID)
+	}
+	if filter.RoomName != "" {
+		addCondition("room_name = $%d", filter.RoomName)
+	}
+	if filter.ProjectID > 0 {
+		addCondition("project_id = $%d", filter.ProjectID)
+	}
+
+	rows, err := database.DB.QueryContext(ctx,
+		"SELECT "+interiorColumns+" FROM interior_designs WHERE "+strings.Join(conditions, " AND ")+" ORDER BY created_at DESC",
+		args...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	designs := make([]InteriorDesign, 0)
+	for rows.Next() {
+		design, err := scanInterior(rows)
+		if err != nil {
+			return nil, err
+		}
+		designs = append(designs, design)
+	}
+	return designs, rows.Err()
+}
+
+func getUserInterior(ctx context.Context, userID, interiorID int) (*InteriorDesign, error) {
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
+
+	row := database.DB.QueryRowContext(ctx,
+		"SELECT "+interiorColumns+" FROM interior_designs WHERE id = $1 AND user_id = $2 AND deleted_at IS NULL",
+		interiorID, userID,
+	)
+	design, err := scanInterior(row)
+	if err != nil {
+		return nil, err
+	}
+	return &design, nil
+}
+
+func softDeleteInterior(ctx context.Context, userID, interiorID int) (bool, error) {
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
+
+	result, err := database.DB.ExecContext(ctx,
+		"UPDATE interior_designs SET deleted_at = CURRENT_TIMESTAMP WHERE id = $1 AND user_id = $2 AND deleted_at IS NULL",
+		interiorID, userID,
+	)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
+
+func restoreDeletedInterior(ctx context.Context, userID, interiorID int) (bool, error) {
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
+
+	result, err := database.DB.ExecContext(ctx,
+		"UPDATE interior_designs SET deleted_at = NULL WHERE id = $1 AND user_id = $2 AND deleted_at IS NOT NULL",
+		interiorID, userID,
+	)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
+
+func purgeInteriors(ctx context.Context, condition string, args ...interface{}) (int, error) {
+	tx, err := database.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, "DELETE FROM interior_designs WHERE "+condition+" RETURNING image_url", args...)
+	if err != nil {
+		return 0, err
+	}
+	var urls []string
+	for rows.Next() {
+		var url string
+		if err := rows.Scan(&url); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		urls = append(urls, url)
+	}
+	if err := rows.Err(); err != nil {
+		rows.Close()
+		return 0, err
+	}
+	rows.Close()
+
+	if err := storage.ReleaseURLs(ctx, tx, urls...); err != nil {
+		return 0, err
+	}
+
+	return len(urls), tx.Commit()
+}
+
+func PurgeUserInteriors(ctx context.Context, userID int) error {
+	_, err := purgeInteriors(ctx, "user_id = $1", userID)
+	return err
+}
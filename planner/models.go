@@ -19,4 +19,9 @@ type PlanResponse struct {
 	CreatedAt string   `json:"created_at"`
 	UpdatedAt string   `json:"updated_at"`
 	RoomData  string   `json:"room_data"`
+	DeletedAt string   `json:"deleted_at,omitempty"`
+	ProjectID int      `json:"project_id,omitempty"`
+	FolderID  int      `json:"folder_id,omitempty"`
+	Favorite  bool     `json:"favorite"`
+	Tags      []string `json:"tags"`
 }
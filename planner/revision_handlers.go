@@ -0,0 +1,163 @@
+package planner
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/planer/backend/internal/audit"
+	"github.com/planer/backend/internal/database"
+)
+
+func ListPlanRevisionsHandler(c *gin.Context) {
+	if database.MockMode {
+		c.JSON(http.StatusOK, []PlanRevision{})
+		return
+	}
+
+	userID, ok := requireUserID(c)
+	if !ok {
+		return
+	}
+	planID, ok := intParam(c, "id")
+	if !ok {
+		return
+	}
+
+	revisions, err := listPlanRevisions(c.Request.Context(), userID, planID)
+	if err != nil {
+		log.Printf("Ошибка при получении истории плана: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Не удалось получить историю плана"})
+		return
+	}
+
+	c.JSON(http.StatusOK, revisions)
+}
+
+func GetPlanRevisionHandler(c *gin.Context) {
+	if !requireDatabase(c) {
+		return
+	}
+
+	userID, ok := requireUserID(c)
+	if !ok {
+		return
+	}
+	planID, ok := intParam(c, "id")
+	if !ok {
+		return
+	}
+	revision, ok := intParam(c, "revision")
+	if !ok {
+		return
+	}
+
+	rev, ok := loadPlanRevision(c, userID, planID, revision)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, rev)
+}
+
+func RestorePlanRevisionHandler(c *gin.Context) {
+	if !requireDatabase(c) {
+		return
+	}
+
+	userID, ok := requireUserID(c)
+	if !ok {
+		return
+	}
+	planID, ok := intParam(c, "id")
+	if !ok {
+		return
+	}
+	revision, ok := intParam(c, "revision")
+	if !ok {
+		return
+	}
+
+	rev, ok := loadPlanRevision(c, userID, planID, revision)
+	if !ok {
+		return
+	}
+
+	rooms, err := parseRoomData(rev.Plan.RoomData)
+	if err != nil {
+		log.Printf("Повреждены данные комнат в версии %d плана %d: %v", revision, planID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Не удалось восстановить версию плана"})
+		return
+	}
+
+	plan := *rev.Plan
+	summary := fmt.Sprintf("Восстановлена версия %d", revision)
+	if err := updatePlan(c.Request.Context(), userID, planID, &plan, rooms, summary); err != nil {
+		log.Printf("Ошибка при восстановлении версии плана: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Не удалось восстановить версию плана"})
+		return
+	}
+
+	audit.Record(c, audit.Entry{Action: audit.ActionPlanUpdate, TargetType: audit.TargetPlan, TargetID: planID,
+		Details: map[string]interface{}{"summary": summary, "restored_revision": revision}})
+
+	c.JSON(http.StatusOK, plan)
+}
+
+func DiffPlanRevisionsHandler(c *gin.Context) {
+	if !requireDatabase(c) {
+		return
+	}
+
+	userID, ok := requireUserID(c)
+	if !ok {
+		return
+	}
+	planID, ok := intParam(c, "id")
+	if !ok {
+		return
+	}
+
+	from, errFrom := strconv.Atoi(c.Query("from"))
+	to, errTo := strconv.Atoi(c.Query("to"))
+	if errFrom != nil || errTo != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Параметры from и to должны быть номерами версий"})
+		return
+	}
+
+	fromRev, ok := loadPlanRevision(c, userID, planID, from)
+	if !ok {
+		return
+	}
+	toRev, ok := loadPlanRevision(c, userID, planID, to)
+	if !ok {
+		return
+	}
+
+	diff, err := diffPlans(fromRev, toRev)
+	if err != nil {
+		log.Printf("Ошибка при сравнении версий плана: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Не удалось сравнить версии плана"})
+		return
+	}
+
+	c.JSON(http.StatusOK, diff)
+}
+
+func loadPlanRevision(c *gin.Context, userID, planID, revision int) (*PlanRevision, bool) {
+	rev, err := getPlanRevision(c.Request.Context(), userID, planID, revision)
+	if errors.Is(err, sql.ErrNoRows) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Версия плана не найдена"})
+		return nil, false
+	}
+	if err != nil {
+		log.Printf("Ошибка при получении версии плана: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Не удалось получить версию плана"})
+		return nil, false
+	}
+	return rev, true
+}
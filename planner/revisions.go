@@ -0,0 +1,206 @@
+package planner
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/planer/backend/internal/database"
+	"github.com/planer/backend/internal/storage"
+)
+
+const revisionEpsilon = 0.01
+
+type PlanRevision struct {
+	ID        int           `json:"id"`
+	PlanID    string        `json:"plan_id"`
+	Revision  int           `json:"revision"`
+	AuthorID  int           `json:"author_id"`
+	Summary   string        `json:"summary"`
+	CreatedAt string        `json:"created_at"`
+	Plan      *PlanResponse `json:"plan,omitempty"`
+}
+
+type FieldChange struct {
+	Field  string      `json:"field"`
+	Before interface{} `json:"before"`
+	After  interface{} `json:"after"`
+}
+
+type RoomChange struct {
+	Name   string `json:"name"`
+	Before Room   `json:"before"`
+	After  Room   `json:"after"`
+}
+
+type PlanDiff struct {
+	From    int           `json:"from"`
+	To      int           `json:"to"`
+	Fields  []FieldChange `json:"fields"`
+	Added   []Room        `json:"added"`
+	Removed []Room        `json:"removed"`
+	Resized []RoomChange  `json:"resized"`
+	Moved   []RoomChange  `json:"moved"`
+}
+
+func insertPlanRevision(ctx context.Context, tx *sql.Tx, planID, authorID int, plan *PlanResponse, summary string) error {
+	document, err := json.Marshal(plan)
+	if err != nil {
+		return err
+	}
+
+	var revision int
+	err = tx.QueryRowContext(ctx,
+		"SELECT COALESCE(MAX(revision), 0) + 1 FROM plan_revisions WHERE plan_id = $1",
+		planID,
+	).Scan(&revision)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx,
+		"INSERT INTO plan_revisions (plan_id, revision, author_id, summary, document) VALUES ($1, $2, $3, $4, $5)",
+		planID, revision, authorID, summary, string(document),
+	)
+	if err != nil {
+		return err
+	}
+
+	return storage.RetainURLs(ctx, tx, plan.FloorPlan, plan.Render3D)
+}
+
+func listPlanRevisions(ctx context.Context, userID, planID int) ([]PlanRevision, error) {
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
+
+	rows, err := database.DB.QueryContext(ctx,
+		`SELECT r.id, r.revision, r.author_id, r.summary, r.created_at
+		FROM plan_revisions r
+		JOIN apartment_plans p ON p.id = r.plan_id
+		WHERE r.plan_id = $1 AND p.user_id = $2 AND p.deleted_at IS NULL
+		ORDER BY r.revision DESC`,
+		planID, userID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	revisions := make([]PlanRevision, 0)
+	for rows.Next() {
+		var rev PlanRevision
+		var authorID sql.NullInt64
+		var createdAt time.Time
+		if err := rows.Scan(&rev.ID, &rev.Revision, &authorID, &rev.Summary, &createdAt); err != nil {
+			return nil, err
+		}
+		rev.PlanID = strconv.Itoa(planID)
+		rev.AuthorID = int(authorID.Int64)
+		rev.CreatedAt = createdAt.Format(time.RFC3339)
+		revisions = append(revisions, rev)
+	}
+	return revisions, rows.Err()
+}
+
+func getPlanRevision(ctx context.Context, userID, planID, revision int) (*PlanRevision, error) {
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
+
+	var rev PlanRevision
+	var authorID sql.NullInt64
+	var createdAt time.Time
+	var document string
+	err := database.DB.QueryRowContext(ctx,
+		`SELECT r.id, r.revision, r.author_id, r.summary, r.document, r.created_at
+		FROM plan_revisions r
+		JOIN apartment_plans p ON p.id = r.plan_id
+		WHERE r.plan_id = $1 AND r.revision = $2 AND p.user_id = $3 AND p.deleted_at IS NULL`,
+		planID, revision, userID,
+	).Scan(&rev.ID, &rev.Revision, &authorID, &rev.Summary, &document, &createdAt)
+	if err != nil {
+		return nil, err
+	}
+
+	var plan PlanResponse
+	if err := json.Unmarshal([]byte(document), &plan); err != nil {
+		return nil, err
+	}
+
+	rev.PlanID = strconv.Itoa(planID)
+	rev.AuthorID = int(authorID.Int64)
+	rev.CreatedAt = createdAt.Format(time.RFC3339)
+	rev.Plan = &plan
+	return &rev, nil
+}
+
+func diffPlans(from, to *PlanRevision) (*PlanDiff, error) {
+	diff := &PlanDiff{
+		From:    from.Revision,
+		To:      to.Revision,
+		Fields:  make([]FieldChange, 0),
+		Added:   make([]Room, 0),
+		Removed: make([]Room, 0),
+		Resized: make([]RoomChange, 0),
+		Moved:   make([]RoomChange, 0),
+	}
+
+	before, after := from.Plan, to.Plan
+	if before.Title != after.Title {
+		diff.Fields = append(diff.Fields, FieldChange{Field: "title", Before: before.Title, After: after.Title})
+	}
+	if before.Area != after.Area {
+		diff.Fields = append(diff.Fields, FieldChange{Field: "area", Before: before.Area, After: after.Area})
+	}
+	if before.Rooms != after.Rooms {
+		diff.Fields = append(diff.Fields, FieldChange{Field: "rooms", Before: before.Rooms, After: after.Rooms})
+	}
+	if before.Style != after.Style {
+		diff.Fields = append(diff.Fields, FieldChange{Field: "style", Before: before.Style, After: after.Style})
+	}
+
+	beforeRooms, err := parseRoomData(before.RoomData)
+	if err != nil {
+		return nil, err
+	}
+	afterRooms, err := parseRoomData(after.RoomData)
+	if err != nil {
+		return nil, err
+	}
+
+	remaining := make(map[string]Room, len(beforeRooms))
+	for _, room := range beforeRooms {
+		remaining[room.Name] = room
+	}
+
+	for _, room := range afterRooms {
+		old, ok := remaining[room.Name]
+		if !ok {
+			diff.Added = append(diff.Added, room)
+			continue
+		}
+		delete(remaining, room.Name)
+
+		change := RoomChange{Name: room.Name, Before: old, After: room}
+		if changed(old.Area, room.Area) || changed(old.Width, room.Width) || changed(old.Height, room.Height) {
+			diff.Resized = append(diff.Resized, change)
+		}
+		if changed(old.X, room.X) || changed(old.Y, room.Y) {
+			diff.Moved = append(diff.Moved, change)
+		}
+	}
+
+	for _, room := range beforeRooms {
+		if _, ok := remaining[room.Name]; ok {
+			diff.Removed = append(diff.Removed, room)
+		}
+	}
+
+	return diff, nil
+}
+
+func changed(a, b float64) bool {
+	return math.Abs(a-b) > revisionEpsilon
+}
@@ -0,0 +1,115 @@
+package planner
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/planer/backend/internal/database"
+)
+
+const searchConfig = "russian"
+
+func planSearchQuery(placeholder string) string {
+	return "websearch_to_tsquery('" + searchConfig + "', " + placeholder + ")"
+}
+
+func searchTerms(query string) []string {
+	return strings.Fields(strings.ToLower(query))
+}
+
+func filterPlansByText(ctx context.Context, plans []PlanResponse, query string) ([]PlanResponse, error) {
+	terms := searchTerms(query)
+	if len(terms) == 0 {
+		return plans, nil
+	}
+
+	notes := make(map[int]string)
+	matched := make([]PlanResponse, 0, len(plans))
+	for _, plan := range plans {
+		parts := []string{plan.Title, plan.Style}
+		parts = append(parts, plan.Features...)
+		parts = append(parts, plan.Tags...)
+
+		rooms, _ := parseRoomData(plan.RoomData)
+		for _, room := range rooms {
+			parts = append(parts, room.Name)
+		}
+
+		if plan.ProjectID > 0 {
+			text, ok := notes[plan.ProjectID]
+			if !ok {
+				var err error
+				if text, err = loadProjectNotesText(ctx, plan.ProjectID); err != nil {
+					return nil, err
+				}
+				notes[plan.ProjectID] = text
+			}
+			parts = append(parts, text)
+		}
+
+		document := strings.ToLower(strings.Join(parts, " "))
+		found := true
+		for _, term := range terms {
+			if !strings.Contains(document, term) {
+				found = false
+				break
+			}
+		}
+		if found {
+			matched = append(matched, plan)
+		}
+	}
+	return matched, nil
+}
+
+func loadProjectNotesText(ctx context.Context, projectID int) (string, error) {
+	rows, err := database.DB.QueryContext(ctx, "SELECT body FROM project_notes WHERE project_id = $1", projectID)
+	if err != nil {
+		return "", err
+	}
+	defer rows.Close()
+
+	var bodies []string
+	for rows.Next() {
+		var body string
+		if err := rows.Scan(&body); err != nil {
+			return "", err
+		}
+		bodies = append(bodies, body)
+	}
+	return strings.Join(bodies, " "), rows.Err()
+}
+
+func SearchPlansHandler(c *gin.Context) {
+	if database.MockMode {
+		c.JSON(http.StatusOK, []PlanResponse{})
+		return
+	}
+
+	userID, ok := requireUserID(c)
+	if !ok {
+		return
+	}
+
+	filter, err := parsePlanFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Некорректные параметры фильтра: " + err.Error()})
+		return
+	}
+	if filter.Query == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Укажите поисковый запрос"})
+		return
+	}
+
+	plans, err := listUserPlans(c.Request.Context(), userID, filter)
+	if err != nil {
+		log.Printf("Ошибка при поиске планов: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Не удалось выполнить поиск"})
+		return
+	}
+
+	c.JSON(http.StatusOK, plans)
+}
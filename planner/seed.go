@@ -0,0 +1,71 @@
+package planner
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"strconv"
+)
+
+type samplePlan struct {
+	Area     int
+	Rooms    int
+	Style    string
+	Features []string
+	Tags     []string
+	Favorite bool
+}
+
+var sampleGallery = []samplePlan{
+	{Area: 32, Rooms: 1, Style: "minimalist", Features: []string{"балкон"}, Tags: []string{"студия", "аренда"}},
+	{Area: 45, Rooms: 2, Style: "scandinavian", Features: []string{"гардеробная"}, Tags: []string{"семья"}, Favorite: true},
+	{Area: 58, Rooms: 2, Style: "modern", Features: []string{"балкон", "кабинет"}, Tags: []string{"новостройка"}},
+	{Area: 74, Rooms: 3, Style: "loft", Features: []string{"кабинет"}, Tags: []string{"лофт", "дизайн"}},
+	{Area: 88, Rooms: 3, Style: "classic", Features: []string{"гардеробная", "балкон"}, Tags: []string{"семья"}},
+	{Area: 120, Rooms: 4, Style: "provence", Features: []string{"терраса", "гардеробная"}, Tags: []string{"загород"}, Favorite: true},
+}
+
+func SeedSamplePlans(ctx context.Context, userID int) (int, error) {
+	existing, err := listUserPlans(ctx, userID, PlanFilter{})
+	if err != nil {
+		return 0, err
+	}
+	if len(existing) > 0 {
+		return 0, nil
+	}
+
+	for _, sample := range sampleGallery {
+		generator := &FloorPlanGenerator{
+			TotalArea: float64(sample.Area),
+			Rooms:     sample.Rooms,
+			Style:     sample.Style,
+			Features:  sample.Features,
+		}
+		rooms := generator.generateRooms(1.0)
+
+		plan := PlanResponse{
+			Title:     fmt.Sprintf("%s, %d м²", styleTitles[sample.Style], sample.Area),
+			Area:      sample.Area,
+			Rooms:     sample.Rooms,
+			Style:     sample.Style,
+			Features:  sample.Features,
+			FloorPlan: generateFloorPlanURL(rand.Int63(), sample.Style, sample.Rooms, sample.Area),
+			Render3D:  generate3DRenderURL(rand.Int63(), sample.Style, sample.Rooms, sample.Area),
+		}
+		if err := savePlan(ctx, userID, &plan, rooms); err != nil {
+			return 0, err
+		}
+
+		planID, _ := strconv.Atoi(plan.ID)
+		if _, err := setPlanTags(ctx, userID, planID, sample.Tags); err != nil {
+			return 0, err
+		}
+		if sample.Favorite {
+			if _, err := setPlanFavorite(ctx, userID, planID, true); err != nil {
+				return 0, err
+			}
+		}
+	}
+
+	return len(sampleGallery), nil
+}
@@ -0,0 +1,327 @@
+package planner
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/planer/backend/internal/database"
+)
+
+type PlanFilter struct {
+	Style       string
+	Rooms       int
+	MinArea     float64
+	MaxArea     float64
+	RoomName    string
+	MinRoomArea float64
+	MaxRoomArea float64
+	Tags        []string
+	FolderID    int
+	Recursive   bool
+	Favorite    bool
+	Query       string
+}
+
+func parseRoomData(roomData string) ([]Room, error) {
+	if strings.TrimSpace(roomData) == "" {
+		return []Room{}, nil
+	}
+
+	var rooms []Room
+	if err := json.Unmarshal([]byte(roomData), &rooms); err != nil {
+		return nil, err
+	}
+	return rooms, nil
+}
+
+func insertPlanRooms(ctx context.Context, tx *sql.Tx, planID int, rooms []Room) error {
+	for i, room := range rooms {
+		_, err := tx.ExecContext(ctx,
+			"INSERT INTO plan_rooms (plan_id, position, name, area, width, height, x, y) VALUES ($1, $2, $3, $4, $5, $6, $7, $8)",
+			planID, i, room.Name, room.Area, room.Width, room.Height, room.X, room.Y,
+		)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+const planColumns = `p.id, p.title, p.area, p.rooms, p.style, p.features, p.floor_plan, p.render_3d, p.project_id, p.folder_id, p.is_favorite, p.created_at, p.updated_at`
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanPlan(row rowScanner, extra ...interface{}) (PlanResponse, int, error) {
+	var plan PlanResponse
+	var id int
+	var area float64
+	var floorPlan, render3D sql.NullString
+	var projectID, folderID sql.NullInt64
+	var createdAt, updatedAt time.Time
+	dest := []interface{}{&id, &plan.Title, &area, &plan.Rooms, &plan.Style, database.StringArray(&plan.Features),
+		&floorPlan, &render3D, &projectID, &folderID, &plan.Favorite, &createdAt, &updatedAt}
+	err := row.Scan(append(dest, extra...)...)
+	if err != nil {
+		return plan, 0, err
+	}
+	plan.ID = strconv.Itoa(id)
+	plan.Area = int(area)
+	plan.FloorPlan = floorPlan.String
+	plan.Render3D = render3D.String
+	plan.ProjectID = int(projectID.Int64)
+	plan.FolderID = int(folderID.Int64)
+	plan.CreatedAt = createdAt.Format(time.RFC3339)
+	plan.UpdatedAt = updatedAt.Format(time.RFC3339)
+	return plan, id, nil
+}
+
+func savePlan(ctx context.Context, userID int, plan *PlanResponse, rooms []Room) error {
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
+
+	tx, err := database.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var planID int
+	var createdAt, updatedAt time.Time
+	err = tx.QueryRowContext(ctx,
+		`INSERT INTO apartment_plans (user_id, title, area, rooms, style, features, floor_plan, render_3d)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING id, created_at, updated_at`,
+		userID, plan.Title, plan.Area, plan.Rooms, plan.Style, database.StringArray(&plan.Features), plan.FloorPlan, plan.Render3D,
+	).Scan(&planID, &createdAt, &updatedAt)
+	if err != nil {
+		return err
+	}
+
+	if err := insertPlanRooms(ctx, tx, planID, rooms); err != nil {
+		return err
+	}
+
+	fillSavedPlan(plan, planID, createdAt, updatedAt, rooms)
+
+	if err := insertPlanRevision(ctx, tx, planID, userID, plan, "Создание плана"); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func updatePlan(ctx context.Context, userID, planID int, plan *PlanResponse, rooms []Room, summary string) error {
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
+
+	tx, err := database.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var createdAt, updatedAt time.Time
+	err = tx.QueryRowContext(ctx,
+		`UPDATE apartment_plans
+		SET title = $1, area = $2, rooms = $3, style = $4, features = $5, floor_plan = $6, render_3d = $7,
+			updated_at = CURRENT_TIMESTAMP
+		WHERE id = $8 AND user_id = $9 AND deleted_at IS NULL
+		RETURNING created_at, updated_at`,
+		plan.Title, plan.Area, plan.Rooms, plan.Style, database.StringArray(&plan.Features), plan.FloorPlan, plan.Render3D,
+		planID, userID,
+	).Scan(&createdAt, &updatedAt)
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM plan_rooms WHERE plan_id = $1", planID); err != nil {
+		return err
+	}
+	if err := insertPlanRooms(ctx, tx, planID, rooms); err != nil {
+		return err
+	}
+
+	fillSavedPlan(plan, planID, createdAt, updatedAt, rooms)
+
+	if err := insertPlanRevision(ctx, tx, planID, userID, plan, summary); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func fillSavedPlan(plan *PlanResponse, planID int, createdAt, updatedAt time.Time, rooms []Room) {
+	roomDataJSON, _ := json.Marshal(rooms)
+	plan.ID = strconv.Itoa(planID)
+	plan.CreatedAt = createdAt.Format(time.RFC3339)
+	plan.UpdatedAt = updatedAt.Format(time.RFC3339)
+	plan.RoomData = string(roomDataJSON)
+}
+
+func getUserPlan(ctx context.Context, userID, planID int) (*PlanResponse, error) {
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
+
+	row := database.DB.QueryRowContext(ctx,
+		"SELECT "+planColumns+" FROM apartment_plans p WHERE p.id = $1 AND p.user_id = $2 AND p.deleted_at IS NULL",
+		planID, userID,
+	)
+	plan, _, err := scanPlan(row)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := loadPlanDetails(ctx, &plan, planID); err != nil {
+		return nil, err
+	}
+
+	return &plan, nil
+}
+
+func loadPlanDetails(ctx context.Context, plan *PlanResponse, planID int) error {
+	rooms, err := loadPlanRooms(ctx, planID)
+	if err != nil {
+		return err
+	}
+	roomDataJSON, _ := json.Marshal(rooms)
+	plan.RoomData = string(roomDataJSON)
+
+	plan.Tags, err = loadPlanTags(ctx, planID)
+	return err
+}
+
+func loadPlanRooms(ctx context.Context, planID int) ([]Room, error) {
+	rows, err := database.DB.QueryContext(ctx,
+		"SELECT name, area, width, height, x, y FROM plan_rooms WHERE plan_id = $1 ORDER BY position",
+		planID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	rooms := make([]Room, 0)
+	for rows.Next() {
+		var room Room
+		if err := rows.Scan(&room.Name, &room.Area, &room.Width, &room.Height, &room.X, &room.Y); err != nil {
+			return nil, err
+		}
+		rooms = append(rooms, room)
+	}
+	return rooms, rows.Err()
+}
+
+func listUserPlans(ctx context.Context, userID int, filter PlanFilter) ([]PlanResponse, error) {
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
+
+	folderIDs, err := filterFolderIDs(ctx, userID, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	conditions := []string{"p.user_id = $1", "p.deleted_at IS NULL"}
+	args := []interface{}{userID}
+
+	addCondition := func(format string, value interface{}) {
+		args = append(args, value)
+		conditions = append(conditions, fmt.Sprintf(format, len(args)))
+	}
+
+	if filter.Style != "" {
+		addCondition("p.style = $%d", filter.Style)
+	}
+	if filter.Rooms > 0 {
+		addCondition("p.rooms = $%d", filter.Rooms)
+	}
+	if filter.MinArea > 0 {
+		addCondition("p.area >= $%d", filter.MinArea)
+	}
+	if filter.MaxArea > 0 {
+		addCondition("p.area <= $%d", filter.MaxArea)
+	}
+	if filter.Favorite {
+		addCondition("p.is_favorite = $%d", true)
+	}
+	if len(folderIDs) > 0 {
+		placeholders := make([]string, len(folderIDs))
+		for i, folderID := range folderIDs {
+			args = append(args, folderID)
+			placeholders[i] = fmt.Sprintf("$%d", len(args))
+		}
+		conditions = append(conditions, "p.folder_id IN ("+strings.Join(placeholders, ", ")+")")
+	}
+	for _, tag := range filter.Tags {
+		addCondition("EXISTS (SELECT 1 FROM plan_tags t WHERE t.plan_id = p.id AND t.tag = $%d)", tag)
+	}
+
+	if filter.RoomName != "" || filter.MinRoomArea > 0 || filter.MaxRoomArea > 0 {
+		roomConditions := []string{"r.plan_id = p.id"}
+		if filter.RoomName != "" {
+			args = append(args, filter.RoomName+"%")
+			roomConditions = append(roomConditions, fmt.Sprintf("r.name LIKE $%d", len(args)))
+		}
+		if filter.MinRoomArea > 0 {
+			args = append(args, filter.MinRoomArea)
+			roomConditions = append(roomConditions, fmt.Sprintf("r.area >= $%d", len(args)))
+		}
+		if filter.MaxRoomArea > 0 {
+			args = append(args, filter.MaxRoomArea)
+			roomConditions = append(roomConditions, fmt.Sprintf("r.area <= $%d", len(args)))
+		}
+		conditions = append(conditions,
+			"EXISTS (SELECT 1 FROM plan_rooms r WHERE "+strings.Join(roomConditions, " AND ")+")")
+	}
+
+	orderBy := "p.created_at DESC"
+	if filter.Query != "" && !database.IsSQLite() {
+		args = append(args, filter.Query)
+		tsQuery := planSearchQuery(fmt.Sprintf("$%d", len(args)))
+		conditions = append(conditions, "p.search_vector @@ "+tsQuery)
+		orderBy = "ts_rank(p.search_vector, " + tsQuery + ") DESC, " + orderBy
+	}
+
+	query := "SELECT " + planColumns + `
+		FROM apartment_plans p
+		WHERE ` + strings.Join(conditions, " AND ") + `
+		ORDER BY ` + orderBy
+
+	rows, err := database.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	plans := make([]PlanResponse, 0)
+	ids := make([]int, 0)
+	for rows.Next() {
+		plan, id, err := scanPlan(rows)
+		if err != nil {
+			return nil, err
+		}
+		plans = append(plans, plan)
+		ids = append(ids, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	for i, id := range ids {
+		if err := loadPlanDetails(ctx, &plans[i], id); err != nil {
+			return nil, err
+		}
+	}
+
+	if filter.Query != "" && database.IsSQLite() {
+		return filterPlansByText(ctx, plans, filter.Query)
+	}
+	return plans, nil
+}
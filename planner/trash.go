@@ -0,0 +1,274 @@
+package planner
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/planer/backend/internal/audit"
+	"github.com/planer/backend/internal/database"
+	"github.com/planer/backend/internal/storage"
+)
+
+const TrashRetention = 30 * 24 * time.Hour
+
+type TrashedPlan struct {
+	PlanResponse
+	PurgeAt string `json:"purge_at"`
+}
+
+func softDeletePlan(ctx context.Context, userID, planID int) (bool, error) {
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
+
+	result, err := database.DB.ExecContext(ctx,
+		"UPDATE apartment_plans SET deleted_at = CURRENT_TIMESTAMP WHERE id = $1 AND user_id = $2 AND deleted_at IS NULL",
+		planID, userID,
+	)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
+
+func restoreDeletedPlan(ctx context.Context, userID, planID int) (bool, error) {
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
+
+	result, err := database.DB.ExecContext(ctx,
+		"UPDATE apartment_plans SET deleted_at = NULL WHERE id = $1 AND user_id = $2 AND deleted_at IS NOT NULL",
+		planID, userID,
+	)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
+
+func listTrashedPlans(ctx context.Context, userID int) ([]TrashedPlan, error) {
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
+
+	rows, err := database.DB.QueryContext(ctx,
+		"SELECT "+planColumns+", p.deleted_at FROM apartment_plans p WHERE p.user_id = $1 AND p.deleted_at IS NOT NULL ORDER BY p.deleted_at DESC",
+		userID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	plans := make([]TrashedPlan, 0)
+	for rows.Next() {
+		var deletedAt time.Time
+		plan, _, err := scanPlan(rows, &deletedAt)
+		if err != nil {
+			return nil, err
+		}
+		plan.DeletedAt = deletedAt.Format(time.RFC3339)
+		plans = append(plans, TrashedPlan{
+			PlanResponse: plan,
+			PurgeAt:      deletedAt.Add(TrashRetention).Format(time.RFC3339),
+		})
+	}
+	return plans, rows.Err()
+}
+
+func DeletePlanHandler(c *gin.Context) {
+	if !requireDatabase(c) {
+		return
+	}
+
+	userID, ok := requireUserID(c)
+	if !ok {
+		return
+	}
+	planID, ok := intParam(c, "id")
+	if !ok {
+		return
+	}
+
+	deleted, err := softDeletePlan(c.Request.Context(), userID, planID)
+	if err != nil {
+		log.Printf("Ошибка при удалении плана: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Не удалось удалить план"})
+		return
+	}
+	if !deleted {
+		c.JSON(http.StatusNotFound, gin.H{"error": "План не найден"})
+		return
+	}
+
+	audit.Record(c, audit.Entry{Action: audit.ActionPlanDelete, TargetType: audit.TargetPlan, TargetID: planID})
+
+	c.Status(http.StatusNoContent)
+}
+
+func ListTrashHandler(c *gin.Context) {
+	if database.MockMode {
+		c.JSON(http.StatusOK, []TrashedPlan{})
+		return
+	}
+
+	userID, ok := requireUserID(c)
+	if !ok {
+		return
+	}
+
+	plans, err := listTrashedPlans(c.Request.Context(), userID)
+	if err != nil {
+		log.Printf("Ошибка при получении корзины: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Не удалось получить корзину"})
+		return
+	}
+
+	c.JSON(http.StatusOK, plans)
+}
+
+func RestorePlanHandler(c *gin.Context) {
+	if !requireDatabase(c) {
+		return
+	}
+
+	userID, ok := requireUserID(c)
+	if !ok {
+		return
+	}
+	planID, ok := intParam(c, "id")
+	if !ok {
+		return
+	}
+
+	restored, err := restoreDeletedPlan(c.Request.Context(), userID, planID)
+	if err != nil {
+		log.Printf("Ошибка при восстановлении плана: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Не удалось восстановить план"})
+		return
+	}
+	if !restored {
+		c.JSON(http.StatusNotFound, gin.H{"error": "План не найден в корзине"})
+		return
+	}
+
+	audit.Record(c, audit.Entry{Action: audit.ActionPlanRestore, TargetType: audit.TargetPlan, TargetID: planID})
+
+	plan, err := getUserPlan(c.Request.Context(), userID, planID)
+	if err != nil {
+		log.Printf("Ошибка при получении восстановленного плана: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Не удалось получить план"})
+		return
+	}
+
+	c.JSON(http.StatusOK, plan)
+}
+
+func StartTrashPurger(interval time.Duration) {
+	if database.MockMode {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			if err := purgeTrash(context.Background(), time.Now().UTC().Add(-TrashRetention)); err != nil {
+				log.Printf("Ошибка при очистке корзины: %v", err)
+			}
+			<-ticker.C
+		}
+	}()
+}
+
+func purgeTrash(ctx context.Context, cutoff time.Time) error {
+	rows, err := database.DB.QueryContext(ctx,
+		"SELECT id FROM apartment_plans WHERE deleted_at IS NOT NULL AND deleted_at < $1",
+		cutoff,
+	)
+	if err != nil {
+		return err
+	}
+
+	var expired []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return err
+		}
+		expired = append(expired, id)
+	}
+	if err := rows.Err(); err != nil {
+		rows.Close()
+		return err
+	}
+	rows.Close()
+
+	for _, planID := range expired {
+		if err := purgePlan(ctx, planID); err != nil {
+			return err
+		}
+	}
+
+	if len(expired) > 0 {
+		log.Printf("Окончательно удалено планов из корзины: %d", len(expired))
+	}
+
+	purged, err := purgeInteriors(ctx, "deleted_at IS NOT NULL AND deleted_at < $1", cutoff)
+	if err != nil {
+		return err
+	}
+	if purged > 0 {
+		log.Printf("Окончательно удалено интерьеров из корзины: %d", purged)
+	}
+	return nil
+}
+
+func purgePlan(ctx context.Context, planID int) error {
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
+
+	tx, err := database.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, "SELECT document FROM plan_revisions WHERE plan_id = $1", planID)
+	if err != nil {
+		return err
+	}
+	var urls []string
+	for rows.Next() {
+		var document string
+		if err := rows.Scan(&document); err != nil {
+			rows.Close()
+			return err
+		}
+		var plan PlanResponse
+		if err := json.Unmarshal([]byte(document), &plan); err != nil {
+			rows.Close()
+			return err
+		}
+		urls = append(urls, plan.FloorPlan, plan.Render3D)
+	}
+	if err := rows.Err(); err != nil {
+		rows.Close()
+		return err
+	}
+	rows.Close()
+
+	if err := storage.ReleaseURLs(ctx, tx, urls...); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, "DELETE FROM apartment_plans WHERE id = $1", planID); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,99 @@
+package projects
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/planer/backend/internal/auth"
+	"github.com/planer/backend/internal/database"
+)
+
+var (
+	ErrDesignerNotFound = errors.New("пользователь не найден")
+	ErrNotDesigner      = errors.New("пользователь не является дизайнером")
+)
+
+type Designer struct {
+	UserID  int       `json:"user_id"`
+	Name    string    `json:"name"`
+	Email   string    `json:"email"`
+	AddedAt time.Time `json:"added_at"`
+}
+
+func listDesigners(ctx context.Context, projectID int) ([]Designer, error) {
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
+
+	rows, err := database.DB.QueryContext(ctx,
+		`SELECT u.id, u.name, u.email, d.created_at
+		FROM project_designers d
+		JOIN users u ON u.id = d.user_id
+		WHERE d.project_id = $1
+		ORDER BY d.created_at, u.id`,
+		projectID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	designers := make([]Designer, 0)
+	for rows.Next() {
+		var d Designer
+		if err := rows.Scan(&d.UserID, &d.Name, &d.Email, &d.AddedAt); err != nil {
+			return nil, err
+		}
+		designers = append(designers, d)
+	}
+	return designers, rows.Err()
+}
+
+func addDesigner(ctx context.Context, ownerID, projectID int, email string) (*Designer, error) {
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
+
+	var designer Designer
+	var role string
+	err := database.DB.QueryRowContext(ctx,
+		"SELECT id, name, email, role FROM users WHERE LOWER(email) = LOWER($1) AND blocked_at IS NULL",
+		strings.TrimSpace(email),
+	).Scan(&designer.UserID, &designer.Name, &designer.Email, &role)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrDesignerNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if designer.UserID == ownerID || !auth.HasPermission(role, auth.PermManageClientProjects) {
+		return nil, ErrNotDesigner
+	}
+
+	designer.AddedAt = time.Now().UTC()
+	_, err = database.DB.ExecContext(ctx,
+		`INSERT INTO project_designers (project_id, user_id, created_at) VALUES ($1, $2, $3)
+		ON CONFLICT (project_id, user_id) DO NOTHING`,
+		projectID, designer.UserID, designer.AddedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &designer, nil
+}
+
+func removeDesigner(ctx context.Context, projectID, designerID int) (bool, error) {
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
+
+	result, err := database.DB.ExecContext(ctx,
+		"DELETE FROM project_designers WHERE project_id = $1 AND user_id = $2",
+		projectID, designerID,
+	)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
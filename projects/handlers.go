@@ -0,0 +1,560 @@
+package projects
+
+import (
+	"database/sql"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"path/filepath"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/planer/backend/internal/audit"
+	"github.com/planer/backend/internal/database"
+	"github.com/planer/backend/internal/storage"
+)
+
+const maxAttachmentSize = 20 << 20
+
+type ProjectRequest struct {
+	Name        string `json:"name" binding:"required,max=200"`
+	Address     string `json:"address"`
+	Description string `json:"description"`
+}
+
+type AttachRequest struct {
+	ID int `json:"id" binding:"required"`
+}
+
+type NoteRequest struct {
+	Body string `json:"body" binding:"required"`
+}
+
+type DesignerRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+func CreateProjectHandler(c *gin.Context) {
+	if !requireDatabase(c) {
+		return
+	}
+
+	userID, ok := requireUserID(c)
+	if !ok {
+		return
+	}
+
+	var req ProjectRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Некорректные данные: " + err.Error()})
+		return
+	}
+
+	project := Project{Name: req.Name, Address: req.Address, Description: req.Description}
+	if err := createProject(c.Request.Context(), userID, &project); err != nil {
+		log.Printf("Ошибка при создании проекта: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Не удалось создать проект"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, project)
+}
+
+func ListProjectsHandler(c *gin.Context) {
+	if database.MockMode {
+		c.JSON(http.StatusOK, []Project{})
+		return
+	}
+
+	userID, ok := requireUserID(c)
+	if !ok {
+		return
+	}
+
+	projects, err := listProjects(c.Request.Context(), userID)
+	if err != nil {
+		log.Printf("Ошибка при получении проектов: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Не удалось получить проекты"})
+		return
+	}
+
+	c.JSON(http.StatusOK, projects)
+}
+
+func GetProjectHandler(c *gin.Context) {
+	if !requireDatabase(c) {
+		return
+	}
+
+	userID, ok := requireUserID(c)
+	if !ok {
+		return
+	}
+	projectID, ok := intParam(c, "id")
+	if !ok {
+		return
+	}
+
+	project, err := getProject(c.Request.Context(), userID, projectID)
+	if errors.Is(err, sql.ErrNoRows) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Проект не найден"})
+		return
+	}
+	if err != nil {
+		log.Printf("Ошибка при получении проекта: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Не удалось получить проект"})
+		return
+	}
+
+	c.JSON(http.StatusOK, project)
+}
+
+func UpdateProjectHandler(c *gin.Context) {
+	if !requireDatabase(c) {
+		return
+	}
+
+	userID, ok := requireUserID(c)
+	if !ok {
+		return
+	}
+	projectID, ok := intParam(c, "id")
+	if !ok {
+		return
+	}
+
+	var req ProjectRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Некорректные данные: " + err.Error()})
+		return
+	}
+
+	project := Project{ID: projectID, Name: req.Name, Address: req.Address, Description: req.Description}
+	err := updateProject(c.Request.Context(), userID, &project)
+	if errors.Is(err, sql.ErrNoRows) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Проект не найден"})
+		return
+	}
+	if err != nil {
+		log.Printf("Ошибка при обновлении проекта: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Не удалось обновить проект"})
+		return
+	}
+
+	c.JSON(http.StatusOK, project)
+}
+
+func DeleteProjectHandler(c *gin.Context) {
+	if !requireDatabase(c) {
+		return
+	}
+
+	userID, ok := requireUserID(c)
+	if !ok {
+		return
+	}
+	projectID, ok := intParam(c, "id")
+	if !ok {
+		return
+	}
+
+	deleted, err := deleteProject(c.Request.Context(), userID, projectID)
+	respondChange(c, deleted, err, "Проект не найден", "Не удалось удалить проект")
+}
+
+func AttachPlanHandler(c *gin.Context) {
+	if !requireDatabase(c) {
+		return
+	}
+
+	userID, projectID, id, ok := bindAttach(c)
+	if !ok {
+		return
+	}
+
+	attached, err := attachPlan(c.Request.Context(), userID, projectID, id)
+	respondChange(c, attached, err, "Проект или план не найден", "Не удалось добавить план в проект")
+}
+
+func DetachPlanHandler(c *gin.Context) {
+	if !requireDatabase(c) {
+		return
+	}
+
+	userID, ok := requireUserID(c)
+	if !ok {
+		return
+	}
+	projectID, ok := intParam(c, "id")
+	if !ok {
+		return
+	}
+	planID, ok := intParam(c, "planId")
+	if !ok {
+		return
+	}
+
+	detached, err := detachPlan(c.Request.Context(), userID, projectID, planID)
+	respondChange(c, detached, err, "План не найден в проекте", "Не удалось убрать план из проекта")
+}
+
+func AttachInteriorHandler(c *gin.Context) {
+	if !requireDatabase(c) {
+		return
+	}
+
+	userID, projectID, id, ok := bindAttach(c)
+	if !ok {
+		return
+	}
+
+	attached, err := attachInterior(c.Request.Context(), userID, projectID, id)
+	respondChange(c, attached, err, "Проект или дизайн интерьера не найден", "Не удалось добавить интерьер в проект")
+}
+
+func DetachInteriorHandler(c *gin.Context) {
+	if !requireDatabase(c) {
+		return
+	}
+
+	userID, ok := requireUserID(c)
+	if !ok {
+		return
+	}
+	projectID, ok := intParam(c, "id")
+	if !ok {
+		return
+	}
+	interiorID, ok := intParam(c, "interiorId")
+	if !ok {
+		return
+	}
+
+	detached, err := detachInterior(c.Request.Context(), userID, projectID, interiorID)
+	respondChange(c, detached, err, "Интерьер не найден в проекте", "Не удалось убрать интерьер из проекта")
+}
+
+func AddNoteHandler(c *gin.Context) {
+	if !requireDatabase(c) {
+		return
+	}
+
+	userID, projectID, ok := requireProjectAccess(c)
+	if !ok {
+		return
+	}
+
+	var req NoteRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Некорректные данные: " + err.Error()})
+		return
+	}
+
+	note := Note{Body: req.Body}
+	if err := addNote(c.Request.Context(), userID, projectID, &note); err != nil {
+		log.Printf("Ошибка при добавлении заметки: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Не удалось добавить заметку"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, note)
+}
+
+func DeleteNoteHandler(c *gin.Context) {
+	if !requireDatabase(c) {
+		return
+	}
+
+	userID, projectID, ok := requireProjectAccess(c)
+	if !ok {
+		return
+	}
+	noteID, ok := intParam(c, "noteId")
+	if !ok {
+		return
+	}
+
+	deleted, err := deleteNote(c.Request.Context(), userID, projectID, noteID)
+	respondChange(c, deleted, err, "Заметка не найдена", "Не удалось удалить заметку")
+}
+
+func UploadAttachmentHandler(c *gin.Context) {
+	if !requireDatabase(c) {
+		return
+	}
+
+	userID, projectID, ok := requireProjectAccess(c)
+	if !ok {
+		return
+	}
+
+	header, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Файл не передан"})
+		return
+	}
+	if header.Size > maxAttachmentSize {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Файл слишком большой"})
+		return
+	}
+
+	file, err := header.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Не удалось прочитать файл"})
+		return
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(io.LimitReader(file, maxAttachmentSize+1))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Не удалось прочитать файл"})
+		return
+	}
+	if len(data) > maxAttachmentSize {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Файл слишком большой"})
+		return
+	}
+
+	contentType := header.Header.Get("Content-Type")
+	if contentType == "" {
+		contentType = http.DetectContentType(data)
+	}
+
+	url, err := storage.SaveAsset(c.Request.Context(), "attachments", data, contentType)
+	if err != nil {
+		log.Printf("Ошибка при сохранении вложения: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Не удалось сохранить файл"})
+		return
+	}
+
+	attachment := Attachment{
+		Filename:    filepath.Base(header.Filename),
+		ContentType: contentType,
+		Size:        int64(len(data)),
+		URL:         url,
+	}
+	if err := addAttachment(c.Request.Context(), userID, projectID, &attachment); err != nil {
+		log.Printf("Ошибка при добавлении вложения: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Не удалось добавить вложение"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, attachment)
+}
+
+func DeleteAttachmentHandler(c *gin.Context) {
+	if !requireDatabase(c) {
+		return
+	}
+
+	userID, projectID, ok := requireProjectAccess(c)
+	if !ok {
+		return
+	}
+	attachmentID, ok := intParam(c, "attachmentId")
+	if !ok {
+		return
+	}
+
+	deleted, err := deleteAttachment(c.Request.Context(), userID, projectID, attachmentID)
+	respondChange(c, deleted, err, "Вложение не найдено", "Не удалось удалить вложение")
+}
+
+func ListDesignersHandler(c *gin.Context) {
+	if database.MockMode {
+		c.JSON(http.StatusOK, []Designer{})
+		return
+	}
+
+	_, projectID, ok := requireProjectAccess(c)
+	if !ok {
+		return
+	}
+
+	designers, err := listDesigners(c.Request.Context(), projectID)
+	if err != nil {
+		log.Printf("Ошибка при получении дизайнеров проекта: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Не удалось получить дизайнеров проекта"})
+		return
+	}
+
+	c.JSON(http.StatusOK, designers)
+}
+
+func AddDesignerHandler(c *gin.Context) {
+	if !requireDatabase(c) {
+		return
+	}
+
+	userID, projectID, ok := requireOwnProject(c)
+	if !ok {
+		return
+	}
+
+	var req DesignerRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Некорректные данные: " + err.Error()})
+		return
+	}
+
+	designer, err := addDesigner(c.Request.Context(), userID, projectID, req.Email)
+	if errors.Is(err, ErrDesignerNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if errors.Is(err, ErrNotDesigner) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		log.Printf("Ошибка при добавлении дизайнера: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Не удалось добавить дизайнера"})
+		return
+	}
+
+	audit.Record(c, audit.Entry{Action: audit.ActionDesignerAdd, TargetType: audit.TargetProject, TargetID: projectID,
+		Details: map[string]interface{}{"designer_id": designer.UserID}})
+	c.JSON(http.StatusCreated, designer)
+}
+
+func RemoveDesignerHandler(c *gin.Context) {
+	if !requireDatabase(c) {
+		return
+	}
+
+	userID, projectID, ok := requireProjectAccess(c)
+	if !ok {
+		return
+	}
+	designerID, ok := intParam(c, "userId")
+	if !ok {
+		return
+	}
+
+	if designerID != userID {
+		owner, err := IsOwner(c.Request.Context(), projectID, userID)
+		if err != nil {
+			log.Printf("Ошибка при проверке владельца проекта: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Не удалось получить проект"})
+			return
+		}
+		if !owner {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Управлять дизайнерами может только владелец проекта"})
+			return
+		}
+	}
+
+	removed, err := removeDesigner(c.Request.Context(), projectID, designerID)
+	if err == nil && removed {
+		audit.Record(c, audit.Entry{Action: audit.ActionDesignerRemove, TargetType: audit.TargetProject, TargetID: projectID,
+			Details: map[string]interface{}{"designer_id": designerID}})
+	}
+	respondChange(c, removed, err, "Дизайнер не найден в проекте", "Не удалось убрать дизайнера из проекта")
+}
+
+func bindAttach(c *gin.Context) (int, int, int, bool) {
+	userID, ok := requireUserID(c)
+	if !ok {
+		return 0, 0, 0, false
+	}
+	projectID, ok := intParam(c, "id")
+	if !ok {
+		return 0, 0, 0, false
+	}
+
+	var req AttachRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Некорректные данные: " + err.Error()})
+		return 0, 0, 0, false
+	}
+
+	return userID, projectID, req.ID, true
+}
+
+func requireProjectAccess(c *gin.Context) (int, int, bool) {
+	userID, ok := requireUserID(c)
+	if !ok {
+		return 0, 0, false
+	}
+	projectID, ok := intParam(c, "id")
+	if !ok {
+		return 0, 0, false
+	}
+
+	owner, err := CanManage(c.Request.Context(), projectID, userID)
+	if err != nil {
+		log.Printf("Ошибка при проверке доступа к проекту: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Не удалось получить проект"})
+		return 0, 0, false
+	}
+	if !owner {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Проект не найден"})
+		return 0, 0, false
+	}
+
+	return userID, projectID, true
+}
+
+func requireOwnProject(c *gin.Context) (int, int, bool) {
+	userID, ok := requireUserID(c)
+	if !ok {
+		return 0, 0, false
+	}
+	projectID, ok := intParam(c, "id")
+	if !ok {
+		return 0, 0, false
+	}
+
+	owner, err := IsOwner(c.Request.Context(), projectID, userID)
+	if err != nil {
+		log.Printf("Ошибка при проверке владельца проекта: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Не удалось получить проект"})
+		return 0, 0, false
+	}
+	if !owner {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Проект не найден"})
+		return 0, 0, false
+	}
+
+	return userID, projectID, true
+}
+
+func respondChange(c *gin.Context, changed bool, err error, notFound, failed string) {
+	if err != nil {
+		log.Printf("%s: %v", failed, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": failed})
+		return
+	}
+	if !changed {
+		c.JSON(http.StatusNotFound, gin.H{"error": notFound})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+func requireDatabase(c *gin.Context) bool {
+	if database.MockMode {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "База данных недоступна"})
+		return false
+	}
+	return true
+}
+
+func requireUserID(c *gin.Context) (int, bool) {
+	userID, exists := c.Get("userID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Требуется авторизация"})
+		return 0, false
+	}
+	return userID.(int), true
+}
+
+func intParam(c *gin.Context, name string) (int, bool) {
+	value, err := strconv.Atoi(c.Param(name))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Некорректный идентификатор"})
+		return 0, false
+	}
+	return value, true
+}
@@ -0,0 +1,525 @@
+package projects
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/planer/backend/internal/database"
+	"github.com/planer/backend/internal/storage"
+)
+
+type Project struct {
+	ID          int       `json:"id"`
+	OwnerID     int       `json:"owner_id"`
+	Name        string    `json:"name"`
+	Address     string    `json:"address"`
+	Description string    `json:"description"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+type ProjectPlan struct {
+	ID    int    `json:"id"`
+	Title string `json:"title"`
+	Style string `json:"style"`
+	Area  int    `json:"area"`
+	Rooms int    `json:"rooms"`
+}
+
+type ProjectInterior struct {
+	ID        int       `json:"id"`
+	RoomType  string    `json:"room_type"`
+	Style     string    `json:"style"`
+	URL       string    `json:"url"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type Note struct {
+	ID        int       `json:"id"`
+	AuthorID  int       `json:"author_id"`
+	Body      string    `json:"body"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type Attachment struct {
+	ID          int       `json:"id"`
+	Filename    string    `json:"filename"`
+	ContentType string    `json:"content_type"`
+	Size        int64     `json:"size"`
+	URL         string    `json:"url"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+type ProjectDetails struct {
+	Project
+	Plans       []ProjectPlan     `json:"plans"`
+	Interiors   []ProjectInterior `json:"interiors"`
+	Notes       []Note            `json:"notes"`
+	Attachments []Attachment      `json:"attachments"`
+}
+
+const projectAccess = `(p.user_id = $%[1]d OR EXISTS (
+	SELECT 1 FROM project_designers d WHERE d.project_id = p.id AND d.user_id = $%[1]d))`
+
+func accessCondition(arg int) string {
+	return fmt.Sprintf(projectAccess, arg)
+}
+
+func IsOwner(ctx context.Context, projectID, userID int) (bool, error) {
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
+
+	var count int
+	err := database.DB.QueryRowContext(ctx,
+		"SELECT COUNT(*) FROM projects WHERE id = $1 AND user_id = $2",
+		projectID, userID,
+	).Scan(&count)
+	return count > 0, err
+}
+
+func CanManage(ctx context.Context, projectID, userID int) (bool, error) {
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
+
+	var count int
+	err := database.DB.QueryRowContext(ctx,
+		"SELECT COUNT(*) FROM projects p WHERE p.id = $1 AND "+accessCondition(2),
+		projectID, userID,
+	).Scan(&count)
+	return count > 0, err
+}
+
+func createProject(ctx context.Context, userID int, project *Project) error {
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
+
+	project.OwnerID = userID
+	return database.DB.QueryRowContext(ctx,
+		`INSERT INTO projects (user_id, name, address, description) VALUES ($1, $2, $3, $4)
+		RETURNING id, created_at, updated_at`,
+		userID, project.Name, project.Address, project.Description,
+	).Scan(&project.ID, &project.CreatedAt, &project.UpdatedAt)
+}
+
+func updateProject(ctx context.Context, userID int, project *Project) error {
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
+
+	return database.DB.QueryRowContext(ctx,
+		`UPDATE projects AS p SET name = $1, address = $2, description = $3, updated_at = CURRENT_TIMESTAMP
+		WHERE p.id = $4 AND `+accessCondition(5)+`
+		RETURNING user_id, created_at, updated_at`,
+		project.Name, project.Address, project.Description, project.ID, userID,
+	).Scan(&project.OwnerID, &project.CreatedAt, &project.UpdatedAt)
+}
+
+func listProjects(ctx context.Context, userID int) ([]Project, error) {
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
+
+	rows, err := database.DB.QueryContext(ctx,
+		`SELECT p.id, p.user_id, p.name, p.address, p.description, p.created_at, p.updated_at
+		FROM projects p
+		WHERE `+accessCondition(1)+`
+		ORDER BY p.updated_at DESC`,
+		userID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	projects := make([]Project, 0)
+	for rows.Next() {
+		var p Project
+		if err := rows.Scan(&p.ID, &p.OwnerID, &p.Name, &p.Address, &p.Description, &p.CreatedAt, &p.UpdatedAt); err != nil {
+			return nil, err
+		}
+		projects = append(projects, p)
+	}
+	return projects, rows.Err()
+}
+
+func getProject(ctx context.Context, userID, projectID int) (*ProjectDetails, error) {
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
+
+	details := &ProjectDetails{}
+	p := &details.Project
+	err := database.DB.QueryRowContext(ctx,
+		`SELECT p.id, p.user_id, p.name, p.address, p.description, p.created_at, p.updated_at
+		FROM projects p
+		WHERE p.id = $1 AND `+accessCondition(2),
+		projectID, userID,
+	).Scan(&p.ID, &p.OwnerID, &p.Name, &p.Address, &p.Description, &p.CreatedAt, &p.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	if details.Plans, err = loadProjectPlans(ctx, projectID); err != nil {
+		return nil, err
+	}
+	if details.Interiors, err = loadProjectInteriors(ctx, projectID); err != nil {
+		return nil, err
+	}
+	if details.Notes, err = loadProjectNotes(ctx, projectID); err != nil {
+		return nil, err
+	}
+	if details.Attachments, err = loadProjectAttachments(ctx, projectID); err != nil {
+		return nil, err
+	}
+
+	return details, nil
+}
+
+func loadProjectPlans(ctx context.Context, projectID int) ([]ProjectPlan, error) {
+	rows, err := database.DB.QueryContext(ctx,
+		"SELECT id, title, style, area, rooms FROM apartment_plans WHERE project_id = $1 AND deleted_at IS NULL ORDER BY id",
+		projectID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	plans := make([]ProjectPlan, 0)
+	for rows.Next() {
+		var plan ProjectPlan
+		var area float64
+		if err := rows.Scan(&plan.ID, &plan.Title, &plan.Style, &area, &plan.Rooms); err != nil {
+			return nil, err
+		}
+		plan.Area = int(area)
+		plans = append(plans, plan)
+	}
+	return plans, rows.Err()
+}
+
+func loadProjectInteriors(ctx context.Context, projectID int) ([]ProjectInterior, error) {
+	rows, err := database.DB.QueryContext(ctx,
+		"SELECT id, room_type, style, image_url, created_at FROM interior_designs WHERE project_id = $1 AND deleted_at IS NULL ORDER BY id",
+		projectID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	interiors := make([]ProjectInterior, 0)
+	for rows.Next() {
+		var interior ProjectInterior
+		if err := rows.Scan(&interior.ID, &interior.RoomType, &interior.Style, &interior.URL, &interior.CreatedAt); err != nil {
+			return nil, err
+		}
+		interiors = append(interiors, interior)
+	}
+	return interiors, rows.Err()
+}
+
+func loadProjectNotes(ctx context.Context, projectID int) ([]Note, error) {
+	rows, err := database.DB.QueryContext(ctx,
+		"SELECT id, author_id, body, created_at, updated_at FROM project_notes WHERE project_id = $1 ORDER BY id",
+		projectID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	notes := make([]Note, 0)
+	for rows.Next() {
+		var note Note
+		var authorID sql.NullInt64
+		if err := rows.Scan(&note.ID, &authorID, &note.Body, &note.CreatedAt, &note.UpdatedAt); err != nil {
+			return nil, err
+		}
+		note.AuthorID = int(authorID.Int64)
+		notes = append(notes, note)
+	}
+	return notes, rows.Err()
+}
+
+func loadProjectAttachments(ctx context.Context, projectID int) ([]Attachment, error) {
+	rows, err := database.DB.QueryContext(ctx,
+		"SELECT id, filename, content_type, size, url, created_at FROM project_attachments WHERE project_id = $1 ORDER BY id",
+		projectID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	attachments := make([]Attachment, 0)
+	for rows.Next() {
+		var a Attachment
+		if err := rows.Scan(&a.ID, &a.Filename, &a.ContentType, &a.Size, &a.URL, &a.CreatedAt); err != nil {
+			return nil, err
+		}
+		attachments = append(attachments, a)
+	}
+	return attachments, rows.Err()
+}
+
+func deleteProject(ctx context.Context, userID, projectID int) (bool, error) {
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
+
+	tx, err := database.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	urls, err := attachmentURLs(ctx, tx, "project_id = $1", projectID)
+	if err != nil {
+		return false, err
+	}
+
+	for _, stmt := range []string{
+		"UPDATE apartment_plans SET project_id = NULL WHERE project_id = $1",
+		"UPDATE interior_designs SET project_id = NULL WHERE project_id = $1",
+		"DELETE FROM project_notes WHERE project_id = $1",
+		"DELETE FROM project_attachments WHERE project_id = $1",
+	} {
+		if _, err := tx.ExecContext(ctx, stmt, projectID); err != nil {
+			return false, err
+		}
+	}
+
+	result, err := tx.ExecContext(ctx, "DELETE FROM projects WHERE id = $1 AND user_id = $2", projectID, userID)
+	if err != nil {
+		return false, err
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return false, nil
+	}
+
+	if err := storage.ReleaseURLs(ctx, tx, urls...); err != nil {
+		return false, err
+	}
+
+	return true, tx.Commit()
+}
+
+func attachmentURLs(ctx context.Context, tx *sql.Tx, condition string, args ...interface{}) ([]string, error) {
+	rows, err := tx.QueryContext(ctx, "SELECT url FROM project_attachments WHERE "+condition, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var urls []string
+	for rows.Next() {
+		var url string
+		if err := rows.Scan(&url); err != nil {
+			return nil, err
+		}
+		urls = append(urls, url)
+	}
+	return urls, rows.Err()
+}
+
+func attachPlan(ctx context.Context, userID, projectID, planID int) (bool, error) {
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
+
+	result, err := database.DB.ExecContext(ctx,
+		`UPDATE apartment_plans SET project_id = $1
+		WHERE id = $2 AND user_id = $3 AND deleted_at IS NULL
+			AND EXISTS (SELECT 1 FROM projects p WHERE p.id = $1 AND `+accessCondition(3)+`)`,
+		projectID, planID, userID,
+	)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
+
+func detachPlan(ctx context.Context, userID, projectID, planID int) (bool, error) {
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
+
+	result, err := database.DB.ExecContext(ctx,
+		`UPDATE apartment_plans SET project_id = NULL
+		WHERE id = $1 AND project_id = $2
+			AND (user_id = $3 OR EXISTS (SELECT 1 FROM projects WHERE id = $2 AND user_id = $3))`,
+		planID, projectID, userID,
+	)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
+
+func attachInterior(ctx context.Context, userID, projectID, interiorID int) (bool, error) {
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
+
+	result, err := database.DB.ExecContext(ctx,
+		`UPDATE interior_designs SET project_id = $1
+		WHERE id = $2 AND user_id = $3 AND deleted_at IS NULL
+			AND EXISTS (SELECT 1 FROM projects p WHERE p.id = $1 AND `+accessCondition(3)+`)`,
+		projectID, interiorID, userID,
+	)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
+
+func detachInterior(ctx context.Context, userID, projectID, interiorID int) (bool, error) {
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
+
+	result, err := database.DB.ExecContext(ctx,
+		`UPDATE interior_designs SET project_id = NULL
+		WHERE id = $1 AND project_id = $2
+			AND (user_id = $3 OR EXISTS (SELECT 1 FROM projects WHERE id = $2 AND user_id = $3))`,
+		interiorID, projectID, userID,
+	)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
+
+func addNote(ctx context.Context, userID, projectID int, note *Note) error {
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
+
+	note.AuthorID = userID
+	return database.DB.QueryRowContext(ctx,
+		`INSERT INTO project_notes (project_id, author_id, body) VALUES ($1, $2, $3)
+		RETURNING id, created_at, updated_at`,
+		projectID, userID, note.Body,
+	).Scan(&note.ID, &note.CreatedAt, &note.UpdatedAt)
+}
+
+func deleteNote(ctx context.Context, userID, projectID, noteID int) (bool, error) {
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
+
+	result, err := database.DB.ExecContext(ctx,
+		`DELETE FROM project_notes WHERE id = $1 AND project_id = $2
+		AND (author_id = $3 OR EXISTS (SELECT 1 FROM projects WHERE id = $2 AND user_id = $3))`,
+		noteID, projectID, userID,
+	)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
+
+func addAttachment(ctx context.Context, userID, projectID int, attachment *Attachment) error {
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
+
+	tx, err := database.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRowContext(ctx,
+		`INSERT INTO project_attachments (project_id, uploader_id, filename, content_type, size, url)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, created_at`,
+		projectID, userID, attachment.Filename, attachment.ContentType, attachment.Size, attachment.URL,
+	).Scan(&attachment.ID, &attachment.CreatedAt)
+	if err != nil {
+		return err
+	}
+
+	if err := storage.RetainURLs(ctx, tx, attachment.URL); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func deleteAttachment(ctx context.Context, userID, projectID, attachmentID int) (bool, error) {
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
+
+	tx, err := database.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	urls, err := attachmentURLs(ctx, tx,
+		"id = $1 AND project_id = $2 AND (uploader_id = $3 OR EXISTS (SELECT 1 FROM projects WHERE id = $2 AND user_id = $3))",
+		attachmentID, projectID, userID)
+	if err != nil {
+		return false, err
+	}
+	if len(urls) == 0 {
+		return false, nil
+	}
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM project_attachments WHERE id = $1", attachmentID); err != nil {
+		return false, err
+	}
+	if err := storage.ReleaseURLs(ctx, tx, urls...); err != nil {
+		return false, err
+	}
+
+	return true, tx.Commit()
+}
+
+func PurgeUserProjects(ctx context.Context, userID int) error {
+	rows, err := database.DB.QueryContext(ctx, "SELECT id FROM projects WHERE user_id = $1", userID)
+	if err != nil {
+		return err
+	}
+
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return err
+		}
+		ids = append(ids, id)
+	}
+	if err := rows.Err(); err != nil {
+		rows.Close()
+		return err
+	}
+	rows.Close()
+
+	for _, id := range ids {
+		if _, err := deleteProject(ctx, userID, id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func ExportUserProjects(ctx context.Context, userID int) ([]ProjectDetails, error) {
+	list, err := listProjects(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	exports := make([]ProjectDetails, 0, len(list))
+	for _, project := range list {
+		if project.OwnerID != userID {
+			continue
+		}
+		details, err := getProject(ctx, userID, project.ID)
+		if err != nil {
+			return nil, err
+		}
+		exports = append(exports, *details)
+	}
+	return exports, nil
+}
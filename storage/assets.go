@@ -0,0 +1,187 @@
+package storage
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/planer/backend/internal/database"
+)
+
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+var assetExtensions = map[string]string{
+	"image/png":       ".png",
+	"image/jpeg":      ".jpg",
+	"image/webp":      ".webp",
+	"image/gif":       ".gif",
+	"application/pdf": ".pdf",
+}
+
+func SaveAsset(ctx context.Context, prefix string, data []byte, contentType string) (string, error) {
+	sum := sha256.Sum256(data)
+	hash := hex.EncodeToString(sum[:])
+
+	mediaType := strings.ToLower(strings.TrimSpace(strings.Split(contentType, ";")[0]))
+	ext, ok := assetExtensions[mediaType]
+	if !ok {
+		ext = ".bin"
+		if strings.HasPrefix(mediaType, "image/") {
+			ext = ".png"
+		}
+	}
+	key := prefix + "/" + hash[:2] + "/" + hash + ext
+
+	if database.MockMode {
+		if err := Default.Put(ctx, key, data, contentType); err != nil {
+			return "", err
+		}
+		return Default.URL(key), nil
+	}
+
+	claimCtx, cancel := database.WithTimeout(ctx)
+	defer cancel()
+
+	err := database.DB.QueryRowContext(claimCtx,
+		`INSERT INTO assets (hash, key, content_type, size) VALUES ($1, $2, $3, $4)
+		ON CONFLICT (hash) DO UPDATE SET unreferenced_since =
+			CASE WHEN assets.ref_count <= 0 THEN CURRENT_TIMESTAMP ELSE NULL END
+		RETURNING key`,
+		hash, key, contentType, len(data),
+	).Scan(&key)
+	if err != nil {
+		return "", err
+	}
+
+	if err := Default.Put(ctx, key, data, contentType); err != nil {
+		return "", err
+	}
+
+	return Default.URL(key), nil
+}
+
+func RetainURLs(ctx context.Context, exec execer, urls ...string) error {
+	for _, url := range urls {
+		key, ok := Default.KeyFromURL(url)
+		if !ok {
+			continue
+		}
+		_, err := exec.ExecContext(ctx,
+			"UPDATE assets SET ref_count = ref_count + 1, unreferenced_since = NULL WHERE key = $1",
+			key,
+		)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func ReleaseURLs(ctx context.Context, exec execer, urls ...string) error {
+	for _, url := range urls {
+		key, ok := Default.KeyFromURL(url)
+		if !ok {
+			continue
+		}
+		_, err := exec.ExecContext(ctx,
+			`UPDATE assets SET ref_count = ref_count - 1,
+				unreferenced_since = CASE WHEN ref_count <= 1 THEN CURRENT_TIMESTAMP ELSE unreferenced_since END
+			WHERE key = $1 AND ref_count > 0`,
+			key,
+		)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func StartAssetGC(interval, grace time.Duration) {
+	if database.MockMode {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			if err := collectGarbage(context.Background(), time.Now().UTC().Add(-grace)); err != nil {
+				log.Printf("Ошибка при очистке неиспользуемых файлов: %v", err)
+			}
+			<-ticker.C
+		}
+	}()
+}
+
+func collectGarbage(ctx context.Context, cutoff time.Time) error {
+	rows, err := database.DB.QueryContext(ctx,
+		"SELECT hash, key FROM assets WHERE ref_count <= 0 AND unreferenced_since < $1",
+		cutoff,
+	)
+	if err != nil {
+		return err
+	}
+
+	candidates := make(map[string]string)
+	for rows.Next() {
+		var hash, key string
+		if err := rows.Scan(&hash, &key); err != nil {
+			rows.Close()
+			return err
+		}
+		candidates[hash] = key
+	}
+	if err := rows.Err(); err != nil {
+		rows.Close()
+		return err
+	}
+	rows.Close()
+
+	removed := 0
+	for hash, key := range candidates {
+		deleted, err := deleteAsset(ctx, hash, key, cutoff)
+		if err != nil {
+			return err
+		}
+		if deleted {
+			removed++
+		}
+	}
+
+	if removed > 0 {
+		log.Printf("Удалено неиспользуемых файлов: %d", removed)
+	}
+	return nil
+}
+
+func deleteAsset(ctx context.Context, hash, key string, cutoff time.Time) (bool, error) {
+	tx, err := database.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx,
+		"DELETE FROM assets WHERE hash = $1 AND ref_count <= 0 AND unreferenced_since < $2",
+		hash, cutoff,
+	)
+	if err != nil {
+		return false, err
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return false, nil
+	}
+
+	if err := Default.Delete(ctx, key); err != nil {
+		log.Printf("Не удалось удалить файл %s: %v", key, err)
+		return false, nil
+	}
+	return true, tx.Commit()
+}
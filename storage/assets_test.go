@@ -0,0 +1,226 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/planer/backend/internal/database"
+)
+
+func openTestDB(t *testing.T) {
+	t.Helper()
+
+	t.Setenv("MOCK_DB", "")
+	t.Setenv("DB_DRIVER", database.DriverSQLite)
+	t.Setenv("SQLITE_PATH", filepath.Join(t.TempDir(), "test.db"))
+	t.Setenv("DB_REQUIRED", "true")
+	database.MockMode = false
+	database.InitDB()
+	t.Cleanup(database.CloseDB)
+
+	previous := Default
+	Default = NewLocalStorage(t.TempDir(), "/static")
+	t.Cleanup(func() { Default = previous })
+}
+
+func assetRefCount(t *testing.T, url string) (int, bool) {
+	t.Helper()
+
+	key, ok := Default.KeyFromURL(url)
+	if !ok {
+		t.Fatalf("URL %q does not belong to the storage", url)
+	}
+	var refCount int
+	err := database.DB.QueryRow("SELECT ref_count FROM assets WHERE key = $1", key).Scan(&refCount)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, false
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return refCount, true
+}
+
+func TestSaveAssetDeduplicates(t *testing.T) {
+	openTestDB(t)
+	ctx := context.Background()
+	data := []byte("\x89PNG\r\n\x1a\nsame image")
+
+	first, err := SaveAsset(ctx, "plans", data, "image/png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := SaveAsset(ctx, "interiors", data, "image/png; charset=binary")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first != second {
+		t.Fatalf("identical content stored twice: %q and %q", first, second)
+	}
+
+	other, err := SaveAsset(ctx, "plans", []byte("другое изображение"), "image/png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if other == first {
+		t.Fatal("different content must get a different key")
+	}
+
+	var count int
+	if err := database.DB.QueryRow("SELECT COUNT(*) FROM assets").Scan(&count); err != nil {
+		t.Fatal(err)
+	}
+	if count != 2 {
+		t.Fatalf("assets = %d, want 2", count)
+	}
+
+	key, _ := Default.KeyFromURL(first)
+	stored, err := Default.Get(ctx, key)
+	if err != nil || string(stored) != string(data) {
+		t.Fatalf("stored blob = %q, %v", stored, err)
+	}
+}
+
+func TestAssetReferenceCounting(t *testing.T) {
+	openTestDB(t)
+	ctx := context.Background()
+
+	url, err := SaveAsset(ctx, "plans", []byte("plan image"), "image/png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if refs, _ := assetRefCount(t, url); refs != 0 {
+		t.Fatalf("new asset ref_count = %d, want 0", refs)
+	}
+
+	if err := RetainURLs(ctx, database.DB, url, url, "https://example.com/foreign.png", ""); err != nil {
+		t.Fatal(err)
+	}
+	if refs, _ := assetRefCount(t, url); refs != 2 {
+		t.Fatalf("ref_count after two retains = %d, want 2", refs)
+	}
+
+	if err := ReleaseURLs(ctx, database.DB, url); err != nil {
+		t.Fatal(err)
+	}
+	if err := collectGarbage(ctx, time.Now().UTC().Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if refs, ok := assetRefCount(t, url); !ok || refs != 1 {
+		t.Fatalf("referenced asset collected: ref_count = %d, present = %v", refs, ok)
+	}
+
+	if err := ReleaseURLs(ctx, database.DB, url, url); err != nil {
+		t.Fatal(err)
+	}
+	if refs, _ := assetRefCount(t, url); refs != 0 {
+		t.Fatalf("ref_count after extra release = %d, want 0", refs)
+	}
+
+	if err := collectGarbage(ctx, time.Now().UTC().Add(-time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := assetRefCount(t, url); !ok {
+		t.Fatal("asset collected before the grace period ended")
+	}
+
+	if err := collectGarbage(ctx, time.Now().UTC().Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := assetRefCount(t, url); ok {
+		t.Fatal("unreferenced asset survived garbage collection")
+	}
+	key, _ := Default.KeyFromURL(url)
+	if _, err := Default.Get(ctx, key); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("blob after GC: err = %v, want ErrNotFound", err)
+	}
+}
+
+func TestSaveAssetKeepsReferencedAsset(t *testing.T) {
+	openTestDB(t)
+	ctx := context.Background()
+	data := []byte("plan image")
+
+	url, err := SaveAsset(ctx, "plans", data, "image/png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := RetainURLs(ctx, database.DB, url); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := SaveAsset(ctx, "plans", data, "image/png"); err != nil {
+		t.Fatal(err)
+	}
+	if err := collectGarbage(ctx, time.Now().UTC().Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if refs, ok := assetRefCount(t, url); !ok || refs != 1 {
+		t.Fatalf("re-saving a referenced asset made it collectable: ref_count = %d, present = %v", refs, ok)
+	}
+}
+
+type gatedStorage struct {
+	Storage
+	deleting chan struct{}
+	release  chan struct{}
+}
+
+func (g *gatedStorage) Delete(ctx context.Context, key string) error {
+	close(g.deleting)
+	<-g.release
+	return g.Storage.Delete(ctx, key)
+}
+
+func TestSaveAssetDuringGarbageCollection(t *testing.T) {
+	openTestDB(t)
+	ctx := context.Background()
+	data := []byte("plan image")
+
+	url, err := SaveAsset(ctx, "plans", data, "image/png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := database.DB.Exec("UPDATE assets SET unreferenced_since = $1",
+		time.Now().UTC().Add(-2*time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+
+	gated := &gatedStorage{Storage: Default, deleting: make(chan struct{}), release: make(chan struct{})}
+	Default = gated
+
+	gcDone := make(chan error, 1)
+	go func() { gcDone <- collectGarbage(ctx, time.Now().UTC().Add(-time.Hour)) }()
+	<-gated.deleting
+
+	saveDone := make(chan error, 1)
+	go func() {
+		var err error
+		url, err = SaveAsset(ctx, "plans", data, "image/png")
+		saveDone <- err
+	}()
+	select {
+	case err := <-saveDone:
+		saveDone <- err
+	case <-time.After(200 * time.Millisecond):
+	}
+	close(gated.release)
+
+	if err := <-gcDone; err != nil {
+		t.Fatal(err)
+	}
+	if err := <-saveDone; err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := assetRefCount(t, url); !ok {
+		t.Fatal("asset row missing after SaveAsset returned")
+	}
+	key, _ := Default.KeyFromURL(url)
+	if _, err := Default.Get(ctx, key); err != nil {
+		t.Fatalf("blob deleted by a collection that overlapped SaveAsset: %v", err)
+	}
+}
@@ -0,0 +1,31 @@
+package storage
+
+import (
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+func AssetHandler(c *gin.Context) {
+	key := strings.TrimPrefix(c.Param("key"), "/")
+	if key == "" || strings.Contains(key, "..") {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Некорректный путь к файлу"})
+		return
+	}
+
+	if local, ok := Default.(*LocalStorage); ok {
+		c.File(local.path(key))
+		return
+	}
+
+	url, err := Default.SignedURL(c.Request.Context(), key, 0)
+	if err != nil {
+		log.Printf("Ошибка при формировании ссылки на файл %s: %v", key, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Не удалось получить файл"})
+		return
+	}
+
+	c.Redirect(http.StatusFound, url)
+}
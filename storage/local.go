@@ -0,0 +1,65 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+type LocalStorage struct {
+	BaseDir string
+	BaseURL string
+}
+
+func NewLocalStorage(baseDir, baseURL string) *LocalStorage {
+	return &LocalStorage{
+		BaseDir: baseDir,
+		BaseURL: strings.TrimSuffix(baseURL, "/"),
+	}
+}
+
+func (s *LocalStorage) path(key string) string {
+	return filepath.Join(s.BaseDir, filepath.FromSlash(filepath.Clean("/"+key)))
+}
+
+func (s *LocalStorage) Put(ctx context.Context, key string, data []byte, contentType string) error {
+	path := s.path(key)
+	if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+func (s *LocalStorage) Get(ctx context.Context, key string) ([]byte, error) {
+	data, err := os.ReadFile(s.path(key))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	return data, err
+}
+
+func (s *LocalStorage) Delete(ctx context.Context, key string) error {
+	err := os.Remove(s.path(key))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+func (s *LocalStorage) URL(key string) string {
+	return s.BaseURL + "/" + strings.TrimPrefix(key, "/")
+}
+
+func (s *LocalStorage) SignedURL(ctx context.Context, key string, expiry time.Duration) (string, error) {
+	return s.URL(key), nil
+}
+
+func (s *LocalStorage) KeyFromURL(url string) (string, bool) {
+	if !strings.HasPrefix(url, s.BaseURL+"/") {
+		return "", false
+	}
+	return strings.TrimPrefix(url, s.BaseURL+"/"), true
+}
@@ -0,0 +1,67 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"log"
+	"os"
+	"strconv"
+	"time"
+)
+
+var ErrNotFound = errors.New("объект не найден в хранилище")
+
+type Storage interface {
+	Put(ctx context.Context, key string, data []byte, contentType string) error
+	Get(ctx context.Context, key string) ([]byte, error)
+	Delete(ctx context.Context, key string) error
+	URL(key string) string
+	SignedURL(ctx context.Context, key string, expiry time.Duration) (string, error)
+	KeyFromURL(url string) (string, bool)
+}
+
+var Default Storage = NewLocalStorage("./static", "/static")
+
+func InitStorage() {
+	backend := getEnv("STORAGE_BACKEND", "local")
+
+	switch backend {
+	case "local":
+		dir := getEnv("STORAGE_DIR", "./static")
+		baseURL := getEnv("STORAGE_BASE_URL", "/static")
+		Default = NewLocalStorage(dir, baseURL)
+		log.Printf("Хранилище файлов: локальная директория %s", dir)
+	case "s3":
+		useSSL, _ := strconv.ParseBool(getEnv("S3_USE_SSL", "true"))
+		expiry, err := time.ParseDuration(getEnv("S3_URL_EXPIRY", "1h"))
+		if err != nil {
+			log.Fatalf("Некорректное значение S3_URL_EXPIRY: %v", err)
+		}
+
+		s3, err := NewS3Storage(S3Config{
+			Endpoint:  getEnv("S3_ENDPOINT", "localhost:9000"),
+			AccessKey: os.Getenv("S3_ACCESS_KEY"),
+			SecretKey: os.Getenv("S3_SECRET_KEY"),
+			Bucket:    getEnv("S3_BUCKET", "planer"),
+			Region:    getEnv("S3_REGION", "us-east-1"),
+			UseSSL:    useSSL,
+			PublicURL: os.Getenv("S3_PUBLIC_URL"),
+			URLExpiry: expiry,
+		})
+		if err != nil {
+			log.Fatalf("Не удалось подключиться к S3 хранилищу: %v", err)
+		}
+		Default = s3
+		log.Printf("Хранилище файлов: S3 бакет %s", s3.config.Bucket)
+	default:
+		log.Fatalf("Неизвестный тип хранилища: %s", backend)
+	}
+}
+
+func getEnv(key, defaultValue string) string {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	return value
+}
@@ -0,0 +1,225 @@
+package storage
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type fakeObject struct {
+	data        []byte
+	contentType string
+	modified    time.Time
+}
+
+type fakeS3 struct {
+	mu      sync.Mutex
+	buckets map[string]bool
+	objects map[string]fakeObject
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	path := strings.TrimPrefix(r.URL.Path, "/")
+	bucket, key, _ := strings.Cut(path, "/")
+	if key == "" {
+		switch r.Method {
+		case http.MethodHead:
+			if !f.buckets[bucket] {
+				w.WriteHeader(http.StatusNotFound)
+			}
+		case http.MethodPut:
+			f.buckets[bucket] = true
+		default:
+			w.WriteHeader(http.StatusNotImplemented)
+		}
+		return
+	}
+	if !f.buckets[bucket] {
+		f.writeError(w, r, http.StatusNotFound, "NoSuchBucket", bucket, key)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodPut:
+		data, err := readS3Body(r)
+		if err != nil {
+			f.writeError(w, r, http.StatusBadRequest, "IncompleteBody", bucket, key)
+			return
+		}
+		object := fakeObject{data: data, contentType: r.Header.Get("Content-Type"), modified: time.Now().UTC()}
+		f.objects[path] = object
+		w.Header().Set("ETag", etag(object.data))
+	case http.MethodGet, http.MethodHead:
+		object, ok := f.objects[path]
+		if !ok {
+			f.writeError(w, r, http.StatusNotFound, "NoSuchKey", bucket, key)
+			return
+		}
+		w.Header().Set("Content-Type", object.contentType)
+		w.Header().Set("Content-Length", strconv.Itoa(len(object.data)))
+		w.Header().Set("ETag", etag(object.data))
+		w.Header().Set("Last-Modified", object.modified.Format(http.TimeFormat))
+		if r.Method == http.MethodGet {
+			w.Write(object.data)
+		}
+	case http.MethodDelete:
+		delete(f.objects, path)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusNotImplemented)
+	}
+}
+
+func (f *fakeS3) writeError(w http.ResponseWriter, r *http.Request, status int, code, bucket, key string) {
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(status)
+	if r.Method != http.MethodHead {
+		fmt.Fprintf(w, `<?xml version="1.0" encoding="UTF-8"?><Error><Code>%s</Code><Message>%s</Message><BucketName>%s</BucketName><Key>%s</Key></Error>`,
+			code, code, bucket, key)
+	}
+}
+
+func etag(data []byte) string {
+	sum := md5.Sum(data)
+	return `"` + hex.EncodeToString(sum[:]) + `"`
+}
+
+func readS3Body(r *http.Request) ([]byte, error) {
+	if !strings.HasPrefix(r.Header.Get("X-Amz-Content-Sha256"), "STREAMING-") {
+		return io.ReadAll(r.Body)
+	}
+
+	var data bytes.Buffer
+	reader := bufio.NewReader(r.Body)
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		sizeHex, _, _ := strings.Cut(strings.TrimSpace(line), ";")
+		size, err := strconv.ParseInt(sizeHex, 16, 64)
+		if err != nil {
+			return nil, err
+		}
+		if size == 0 {
+			return data.Bytes(), nil
+		}
+		if _, err := io.CopyN(&data, reader, size); err != nil {
+			return nil, err
+		}
+		if _, err := reader.Discard(2); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func newTestS3(t *testing.T) *S3Storage {
+	t.Helper()
+
+	config := S3Config{
+		Endpoint:  os.Getenv("S3_TEST_ENDPOINT"),
+		AccessKey: os.Getenv("S3_TEST_ACCESS_KEY"),
+		SecretKey: os.Getenv("S3_TEST_SECRET_KEY"),
+		Bucket:    "planer-test",
+		Region:    "us-east-1",
+		URLExpiry: time.Hour,
+	}
+	if config.Endpoint == "" {
+		server := httptest.NewServer(&fakeS3{buckets: make(map[string]bool), objects: make(map[string]fakeObject)})
+		t.Cleanup(server.Close)
+		config.Endpoint = strings.TrimPrefix(server.URL, "http://")
+		config.AccessKey = "test"
+		config.SecretKey = "test-secret"
+	}
+
+	s3, err := NewS3Storage(config)
+	if err != nil {
+		t.Fatalf("S3 storage unavailable: %v", err)
+	}
+	return s3
+}
+
+func TestStorageRoundTrip(t *testing.T) {
+	backends := map[string]func(t *testing.T) Storage{
+		"local": func(t *testing.T) Storage { return NewLocalStorage(t.TempDir(), "/static/") },
+		"s3":    func(t *testing.T) Storage { return newTestS3(t) },
+	}
+
+	for name, open := range backends {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			store := open(t)
+			key := "plans/ab/" + strconv.FormatInt(time.Now().UnixNano(), 10) + ".png"
+			data := []byte("\x89PNG\r\n\x1a\nround-trip")
+
+			if _, err := store.Get(ctx, key); !errors.Is(err, ErrNotFound) {
+				t.Fatalf("Get before Put: err = %v, want ErrNotFound", err)
+			}
+			if err := store.Put(ctx, key, data, "image/png"); err != nil {
+				t.Fatal(err)
+			}
+			got, err := store.Get(ctx, key)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, data) {
+				t.Fatalf("Get = %q, want %q", got, data)
+			}
+
+			url := store.URL(key)
+			if parsed, ok := store.KeyFromURL(url); !ok || parsed != key {
+				t.Fatalf("KeyFromURL(%q) = %q, %v", url, parsed, ok)
+			}
+			if _, ok := store.KeyFromURL("https://example.com/" + key); ok {
+				t.Fatal("foreign URL must not map to a key")
+			}
+
+			signed, err := store.SignedURL(ctx, key, time.Minute)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if s3, ok := store.(*S3Storage); ok {
+				if !strings.Contains(signed, "X-Amz-Signature=") || !strings.Contains(signed, "X-Amz-Expires=60") {
+					t.Fatalf("SignedURL = %q, want a presigned URL valid for 60s", signed)
+				}
+				resp, err := http.Get(signed)
+				if err != nil {
+					t.Fatal(err)
+				}
+				body, _ := io.ReadAll(resp.Body)
+				resp.Body.Close()
+				if resp.StatusCode != http.StatusOK || !bytes.Equal(body, data) {
+					t.Fatalf("GET %s from bucket %s: status %d, body %q", signed, s3.config.Bucket, resp.StatusCode, body)
+				}
+			} else if signed != url {
+				t.Fatalf("SignedURL = %q, want %q", signed, url)
+			}
+
+			if err := store.Delete(ctx, key); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := store.Get(ctx, key); !errors.Is(err, ErrNotFound) {
+				t.Fatalf("Get after Delete: err = %v, want ErrNotFound", err)
+			}
+			if err := store.Delete(ctx, key); err != nil {
+				t.Fatalf("deleting a missing key must succeed: %v", err)
+			}
+		})
+	}
+}